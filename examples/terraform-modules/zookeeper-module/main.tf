@@ -1,5 +1,5 @@
 provider "kudo" {
-    kubeconfig = "/Users/tom/.kube/config"
+    config_path = "/Users/tom/.kube/config"
     kudo_version = "0.14.0"
 }
 
@@ -1,6 +1,10 @@
 provider "kudo" {
-    kubeconfig = "/Users/tom/.kube/config"
+    config_path = "/Users/tom/.kube/config"
+}
+
+resource "kudo_installation" "kudo" {
     kudo_version = "0.14.0"
+    wait = true
 }
 
 
@@ -12,11 +16,13 @@ module "kubernetes_cluster" {
 resource "kudo_operator" "zookeeper" {
     operator_name = "zookeeper"
     skip_instance = true
+    depends_on = [kudo_installation.kudo]
 }
 
 resource "kudo_operator" "kafka" {
     operator_name = "kafka"
     skip_instance = true
+    depends_on = [kudo_installation.kudo]
 }
 
 resource "kudo_instance" "zk1" {
@@ -13,6 +13,7 @@ require (
 	k8s.io/apiextensions-apiserver v0.17.2
 	k8s.io/apimachinery v0.17.3
 	k8s.io/client-go v0.17.3
+	sigs.k8s.io/yaml v1.2.0
 // indirect
 )
 
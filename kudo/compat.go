@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/crd"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/manager"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/verifier"
+	"github.com/kudobuilder/kudo/pkg/version"
+)
+
+const (
+	versionCheckWarn = "warn"
+	versionCheckFail = "fail"
+	versionCheckOff  = "off"
+
+	// the API version of the KUDO types the provider is built with
+	kudoAPIVersion = "v1beta1"
+)
+
+// the KUDO controller versions that work with the types the provider is built with,
+// the maximum is exclusive
+var (
+	minSupportedKUDOVersion = version.MustParse("0.14.0")
+	maxSupportedKUDOVersion = version.MustParse("0.15.0")
+)
+
+//installedKUDO describes the KUDO found in the cluster
+type installedKUDO struct {
+	// Namespace the controller runs in, empty without a controller
+	Namespace string
+	// Version of the controller, empty if it can't be told from the image
+	Version string
+	// CRDVersions are the served versions of each KUDO CRD that exists
+	CRDVersions map[string][]string
+	// Unchecked lists what the credentials weren't allowed to look at
+	Unchecked []string
+}
+
+//detectKUDO finds the KUDO CRDs and controller. The controller is looked for in namespace first,
+// then in all namespaces.
+func detectKUDO(client *kube.Client, namespace string) (*installedKUDO, error) {
+	k := &installedKUDO{CRDVersions: map[string][]string{}}
+	for _, obj := range crd.NewInitializer().Resources() {
+		name := obj.(*apiextv1beta1.CustomResourceDefinition).Name
+		existing, err := client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if errors.IsForbidden(err) {
+			k.Unchecked = append(k.Unchecked, "CRD "+name)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting CRD %s: %v", name, err)
+		}
+		k.CRDVersions[name] = servedVersions(existing)
+	}
+
+	ss, err := client.KubeClient.AppsV1().StatefulSets(namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	if err == nil {
+		k.Namespace = namespace
+		k.Version = controllerVersion(ss)
+		return k, nil
+	}
+	if errors.IsForbidden(err) {
+		k.Unchecked = append(k.Unchecked, "KUDO controller in "+namespace)
+		return k, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("error getting KUDO controller: %v", err)
+	}
+
+	controllers, err := client.KubeClient.AppsV1().StatefulSets("").List(metav1.ListOptions{
+		LabelSelector: manager.GenerateLabels().AsSelector().String(),
+		FieldSelector: fields.OneTermEqualSelector("metadata.name", kudoinit.DefaultManagerName).String(),
+	})
+	if errors.IsForbidden(err) {
+		k.Unchecked = append(k.Unchecked, "KUDO controller in other namespaces")
+		return k, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking for the KUDO controller: %v", err)
+	}
+	for _, ss := range controllers.Items {
+		if ss.Name != kudoinit.DefaultManagerName {
+			continue
+		}
+		log.Printf("[WARN] [KUDO] KUDO controller found in namespace %s instead of %s", ss.Namespace, namespace)
+		k.Namespace = ss.Namespace
+		k.Version = controllerVersion(&ss)
+		break
+	}
+	return k, nil
+}
+
+//verify reports everything about the installation the provider can't work with
+func (k *installedKUDO) verify(result *verifier.Result) {
+	for _, u := range k.Unchecked {
+		result.AddWarnings(fmt.Sprintf("not allowed to check the %s", u))
+	}
+	if k.Namespace == "" && len(k.CRDVersions) == 0 {
+		// nothing installed yet, e.g. because kudo_installation installs it
+		return
+	}
+	for _, obj := range crd.NewInitializer().Resources() {
+		name := obj.(*apiextv1beta1.CustomResourceDefinition).Name
+		versions, ok := k.CRDVersions[name]
+		if !ok {
+			result.AddErrors(fmt.Sprintf("KUDO CRD %s is not installed", name))
+			continue
+		}
+		if !contains(versions, kudoAPIVersion) {
+			result.AddErrors(fmt.Sprintf("KUDO CRD %s serves %v, but the provider requires %s", name, versions, kudoAPIVersion))
+		}
+	}
+
+	if k.Namespace == "" {
+		// the CRDs can be installed on their own
+		return
+	}
+	if k.Version == "" {
+		result.AddWarnings(fmt.Sprintf("the version of the KUDO controller in %s can't be determined from its image", k.Namespace))
+		return
+	}
+	v, err := version.New(k.Version)
+	if err != nil {
+		result.AddWarnings(fmt.Sprintf("the KUDO controller in %s has an invalid version %q", k.Namespace, k.Version))
+		return
+	}
+	if v.Compare(minSupportedKUDOVersion.Version) < 0 || v.Compare(maxSupportedKUDOVersion.Version) >= 0 {
+		result.AddErrors(fmt.Sprintf("KUDO %v is installed in %s, the provider supports versions from %v up to, not including, %v",
+			v, k.Namespace, minSupportedKUDOVersion, maxSupportedKUDOVersion))
+	}
+}
+
+//checkKUDOCompatibility detects KUDO and, depending on mode, logs or returns the problems found
+func checkKUDOCompatibility(client *kube.Client, namespace, mode string) (*installedKUDO, error) {
+	if mode == versionCheckOff {
+		return nil, nil
+	}
+	k, err := detectKUDO(client, namespace)
+	if err != nil && mode == versionCheckFail {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("[WARN] [KUDO] Can't check the installed KUDO: %v", err)
+		return nil, nil
+	}
+	log.Printf("[DEBUG] Detected KUDO %+v", *k)
+
+	result := verifier.NewResult()
+	k.verify(&result)
+	for _, w := range result.Warnings {
+		log.Printf("[WARN] [KUDO] %s", w)
+	}
+	if result.IsValid() {
+		return k, nil
+	}
+	if mode == versionCheckFail {
+		return nil, fmt.Errorf("the installed KUDO is not supported by the provider:\n%s", result.ErrorsAsString())
+	}
+	for _, e := range result.Errors {
+		log.Printf("[WARN] [KUDO] %s", e)
+	}
+	return k, nil
+}
+
+func servedVersions(crd *apiextv1beta1.CustomResourceDefinition) []string {
+	versions := []string{}
+	for _, v := range crd.Spec.Versions {
+		if v.Served {
+			versions = append(versions, v.Name)
+		}
+	}
+	if len(versions) == 0 && crd.Spec.Version != "" {
+		versions = append(versions, crd.Spec.Version)
+	}
+	return versions
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+)
+
+func testServedCRDs(version string) []runtime.Object {
+	objs := []runtime.Object{}
+	for _, name := range []string{"operators.kudo.dev", "operatorversions.kudo.dev", "instances.kudo.dev"} {
+		objs = append(objs, &apiextv1beta1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: apiextv1beta1.CustomResourceDefinitionSpec{
+				Versions: []apiextv1beta1.CustomResourceDefinitionVersion{{Name: version, Served: true, Storage: true}},
+			},
+		})
+	}
+	return objs
+}
+
+func TestCheckKUDOCompatibility(t *testing.T) {
+	// nothing installed is fine, kudo_installation may install it later
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extfake.NewSimpleClientset(),
+	}
+	k, err := checkKUDOCompatibility(client, kudoinit.DefaultNamespace, versionCheckFail)
+	assert.NoError(t, err)
+	assert.Equal(t, "", k.Namespace)
+
+	// the controller is found outside of the configured namespace
+	client.ExtClient = extfake.NewSimpleClientset(testServedCRDs("v1beta1")...)
+	opts := InstallOptions{KudoImage: "kudobuilder/controller", Version: "0.14.0", Namespace: "platform"}
+	assert.NoError(t, newManagerStep(opts).Install(client))
+	k, err = checkKUDOCompatibility(client, kudoinit.DefaultNamespace, versionCheckFail)
+	assert.NoError(t, err)
+	assert.Equal(t, "platform", k.Namespace)
+	assert.Equal(t, "0.14.0", k.Version)
+	assert.Equal(t, []string{"v1beta1"}, k.CRDVersions["instances.kudo.dev"])
+
+	// unsupported versions fail, or only warn
+	opts.Version = "0.12.0"
+	assert.NoError(t, newManagerStep(opts).Install(client))
+	_, err = checkKUDOCompatibility(client, "platform", versionCheckFail)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "KUDO 0.12.0 is installed in platform")
+	k, err = checkKUDOCompatibility(client, "platform", versionCheckWarn)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.12.0", k.Version)
+
+	opts.Version = "0.14.0"
+	assert.NoError(t, newManagerStep(opts).Install(client))
+	client.ExtClient = extfake.NewSimpleClientset(testServedCRDs("v1alpha1")...)
+	_, err = checkKUDOCompatibility(client, "platform", versionCheckFail)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "serves [v1alpha1], but the provider requires v1beta1")
+}
+
+func TestEnsureKUDO(t *testing.T) {
+	connected := func(client *kube.Client) Config {
+		c := NewConfig(nil)
+		c.Namespace = kudoinit.DefaultNamespace
+		c.clients.kudoKubeClient = client
+		c.clients.once.Do(func() {})
+		return c
+	}
+
+	// without kudo_version nothing installs KUDO any more, which has to be loud
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extfake.NewSimpleClientset(),
+	}
+	err := connected(client).ensureKUDO()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "KUDO is not installed in the cluster")
+	for _, a := range client.KubeClient.(*fake.Clientset).Actions() {
+		assert.NotEqual(t, "create", a.GetVerb())
+	}
+
+	// KUDO installed by a kudo_installation is fine
+	client.ExtClient = extfake.NewSimpleClientset(testServedCRDs("v1beta1")...)
+	assert.NoError(t, connected(client).ensureKUDO())
+
+	// and so is KUDO managed by someone else, which is verified when connecting
+	client.ExtClient = extfake.NewSimpleClientset()
+	c := connected(client)
+	c.ManageKUDO = false
+	assert.NoError(t, c.ensureKUDO())
+}
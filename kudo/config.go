@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/spf13/afero"
+
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/client-go/dynamic"
+	kubernetes "k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+
+	"github.com/kudobuilder/kudo/pkg/client/clientset/versioned"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/util/kudo"
+)
+
+//errClusterUnknown is returned by the client getters when the provider has no
+// cluster to talk to yet, e.g. because the cluster is created in the same plan
+var errClusterUnknown = errors.New("the Kubernetes cluster is not known yet")
+
+//isClusterUnknown reports whether err was caused by a cluster that can't be resolved yet
+func isClusterUnknown(err error) bool {
+	return errors.Is(err, errClusterUnknown)
+}
+
+//stateClientError explains why a resource that is in the state can't be read. An unknown cluster
+// only means "not created yet" for resources without an ID, so it isn't taken as the resource being
+// gone, which would drop it from the state and create it again.
+func stateClientError(d *schema.ResourceData, err error) error {
+	if isClusterUnknown(err) {
+		return fmt.Errorf("%s is in the state, but %w, check the provider configuration", d.Id(), err)
+	}
+	return err
+}
+
+//Config captures the configuration of the KUDO provider
+type Config struct {
+	// LegacyInstall is set when KUDO should be installed while configuring
+	// the provider, which is the behaviour of kudo_version on the provider block
+	LegacyInstall *InstallOptions
+
+	// Namespace KUDO is expected in, and VersionCheck how to treat an
+	// installed KUDO the provider doesn't support
+	Namespace    string
+	VersionCheck string
+
+	// ManageKUDO is false when the provider must never install or change KUDO,
+	// it then only verifies that KUDO can be used in TenantNamespaces
+	ManageKUDO       bool
+	TenantNamespaces []string
+
+	// DefaultNamespace, DefaultLabels and DefaultAnnotations apply to all
+	// Instances, Operators and OperatorVersions created by the provider
+	DefaultNamespace   string
+	DefaultLabels      map[string]string
+	DefaultAnnotations map[string]string
+
+	// RBACPreflight checks the permissions an operation needs before it changes anything
+	RBACPreflight bool
+
+	// clients is shared between all copies of the Config and is only
+	// populated the first time a client is requested
+	clients *clients
+
+	// repositories are the ones defined by kudo_repository resources
+	repositories *repositoryStore
+
+	// rateLimiter is shared by all clients, it is nil without a provider configuration
+	rateLimiter *measuredRateLimiter
+}
+
+//clients holds the Kubernetes and KUDO clients, created on first use
+type clients struct {
+	once       sync.Once
+	err        error
+	restConfig func() (*restclient.Config, error)
+
+	kubernetesConfig *restclient.Config
+	kubernetesClient *kubernetes.Clientset
+	rawKudoClient    *versioned.Clientset
+	kudoClient       *kudo.Client
+	kudoKubeClient   *kube.Client
+
+	// installed is the KUDO found when connecting
+	installed *installedKUDO
+
+	// ensured is done once KUDO was installed for kudo_version, or found
+	ensured    sync.Once
+	ensuredErr error
+}
+
+//NewConfig returns a Config whose clients are built from restConfig the first time they are needed
+func NewConfig(restConfig func() (*restclient.Config, error)) Config {
+	return Config{
+		ManageKUDO:   true,
+		clients:      &clients{restConfig: restConfig},
+		repositories: newRepositoryStore(afero.NewOsFs(), defaultRepositoryFile()),
+	}
+}
+
+//connect resolves the client configuration and creates all clients. It only runs once.
+func (c Config) connect() error {
+	if c.clients == nil {
+		return fmt.Errorf("KUDO provider is not configured")
+	}
+	c.clients.once.Do(func() {
+		c.clients.err = c.clients.create()
+		if c.clients.err == nil {
+			c.clients.installed, c.clients.err = checkKUDOCompatibility(c.clients.kudoKubeClient, c.Namespace, c.VersionCheck)
+		}
+		if c.clients.err == nil && !c.ManageKUDO {
+			c.clients.err = c.clients.verifyTenant(c.Namespace, c.TenantNamespaces)
+		}
+	})
+	return c.clients.err
+}
+
+//ensureKUDO runs the deprecated install for kudo_version on the provider, or fails when KUDO isn't
+// installed at all. The provider used to install KUDO 0.14.0 without kudo_version, so configurations
+// that relied on it fail here instead of running without KUDO. It is only called by operations that
+// change the cluster anyway, plans never install KUDO.
+func (c Config) ensureKUDO() error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+	c.clients.ensured.Do(func() {
+		cl := c.clients
+		if c.LegacyInstall != nil {
+			log.Printf("[WARN] kudo_version is set on the provider, installing KUDO %v", c.LegacyInstall.Version)
+			cl.ensuredErr = installOrUpgradeKUDO(cl.kudoKubeClient, cl.rawKudoClient, *c.LegacyInstall)
+			return
+		}
+		if !c.ManageKUDO {
+			// verified when connecting
+			return
+		}
+		k, err := detectKUDO(cl.kudoKubeClient, c.Namespace)
+		if err != nil {
+			log.Printf("[WARN] [KUDO] Can't check whether KUDO is installed: %v", err)
+			return
+		}
+		if len(k.CRDVersions) == 0 && k.Namespace == "" && len(k.Unchecked) == 0 {
+			cl.ensuredErr = fmt.Errorf("KUDO is not installed in the cluster. The provider no longer installs KUDO 0.14.0 when kudo_version isn't set, " +
+				"add a kudo_installation resource and make this resource depend on it, or set kudo_version on the provider")
+		}
+	})
+	return c.clients.ensuredErr
+}
+
+//verifyTenant checks that the KUDO found when connecting can be used from namespaces
+func (cl *clients) verifyTenant(namespace string, namespaces []string) error {
+	k := cl.installed
+	if k == nil {
+		var err error
+		k, err = detectKUDO(cl.kudoKubeClient, namespace)
+		if err != nil {
+			return err
+		}
+	}
+	return verifyUsableKUDO(cl.kudoKubeClient, cl.rawKudoClient, k, namespaces)
+}
+
+func (cl *clients) create() error {
+	cfg, err := cl.restConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Host == "" {
+		return errClusterUnknown
+	}
+
+	k, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("Failed to configure: %s", err)
+	}
+	rawKudoClient, err := versioned.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("Failed to obtain client to KUDO CRDs: %v", err)
+	}
+	extClient, err := apiextensionsclient.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("could not get Kubernetes client: %s", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("could not create Kubernetes dynamic client: %s", err)
+	}
+
+	cl.kubernetesConfig = cfg
+	cl.kubernetesClient = k
+	cl.rawKudoClient = rawKudoClient
+	cl.kudoClient = kudo.NewClientFromK8s(rawKudoClient, k)
+	cl.kudoKubeClient = &kube.Client{
+		KubeClient:    k,
+		DynamicClient: dynamicClient,
+		ExtClient:     extClient,
+	}
+	log.Printf("[DEBUG] Created the kube clients for %v", cfg.Host)
+	return nil
+}
+
+//GetKubernetesConfig returns the resolved rest config used by all clients
+func (c Config) GetKubernetesConfig() (*restclient.Config, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.clients.kubernetesConfig, nil
+}
+
+//GetKubernetesClient returns a Kubernetes client from the configuration object
+func (c Config) GetKubernetesClient() (*kubernetes.Clientset, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.clients.kubernetesClient, nil
+}
+
+//GetKudoClient returns a KUDO client from the configuration object
+func (c Config) GetKudoClient() (*kudo.Client, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.clients.kudoClient, nil
+}
+
+//GetRawKudoClient returns the generated clientset for the KUDO CRDs
+func (c Config) GetRawKudoClient() (*versioned.Clientset, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.clients.rawKudoClient, nil
+}
+
+//GetKudoKubernetesClient returns the client bundle used by the KUDO installer
+func (c Config) GetKudoKubernetesClient() (*kube.Client, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.clients.kudoKubeClient, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/manager"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/verifier"
+)
+
+// the grace period KUDO has always been installed with
+const defaultTerminationGracePeriodSeconds = 300
+
+//ControllerOptions customizes the pod of the KUDO controller
+type ControllerOptions struct {
+	Requests                      corev1.ResourceList
+	Limits                        corev1.ResourceList
+	NodeSelector                  map[string]string
+	Tolerations                   []corev1.Toleration
+	Affinity                      *corev1.Affinity
+	PriorityClassName             string
+	TerminationGracePeriodSeconds int64
+
+	// how the controller image turns on leader election, KUDO's own managers don't have any
+	LeaderElectionFlag  string
+	LeaderElectionIDEnv string
+}
+
+// Ensure IF is implemented
+var _ kudoinit.Step = &managerStep{}
+
+//managerStep installs the KUDO controller like manager.Initializer does, with the ControllerOptions
+// and image pull secrets applied to its StatefulSet. An existing StatefulSet is updated to match, so changes are reconciled.
+type managerStep struct {
+	manager.Initializer
+	namespace   string
+	statefulSet *appsv1.StatefulSet
+}
+
+func newManagerStep(o InstallOptions) *managerStep {
+	opts := o.ToKUDOOpts()
+	init := manager.NewInitializer(opts)
+	step := &managerStep{
+		Initializer: init,
+		namespace:   opts.Namespace,
+	}
+	for _, obj := range init.Resources() {
+		if ss, ok := obj.(*appsv1.StatefulSet); ok {
+			step.statefulSet = ss
+		}
+	}
+	o.Controller.apply(step.statefulSet)
+	o.applyHA(step.statefulSet)
+	for _, name := range o.ImagePullSecrets {
+		step.statefulSet.Spec.Template.Spec.ImagePullSecrets = append(step.statefulSet.Spec.Template.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}
+	return step
+}
+
+//PreInstallVerify has nothing to verify, the manager is always installable
+func (m *managerStep) PreInstallVerify(client *kube.Client, result *verifier.Result) error {
+	return nil
+}
+
+//Install creates the StatefulSet and service, or updates the pod template of an existing StatefulSet
+func (m *managerStep) Install(client *kube.Client) error {
+	statefulSets := client.KubeClient.AppsV1().StatefulSets(m.namespace)
+	existing, err := statefulSets.Get(m.statefulSet.Name, metav1.GetOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("stateful set: %v", err)
+	}
+	if err == nil {
+		log.Printf("[DEBUG] Updating KUDO controller StatefulSet %s/%s", m.namespace, m.statefulSet.Name)
+		existing.Spec.Template = m.statefulSet.Spec.Template
+		existing.Spec.Replicas = m.statefulSet.Spec.Replicas
+		_, err = statefulSets.Update(existing)
+		if err != nil {
+			return fmt.Errorf("stateful set: %v", err)
+		}
+	}
+	// the StatefulSet exists now, so this only creates whatever else is missing
+	return m.Initializer.Install(client)
+}
+
+//Resources returns the customized service and StatefulSet
+func (m *managerStep) Resources() []runtime.Object {
+	return m.Initializer.Resources()
+}
+
+//apply sets the options on the manager StatefulSet
+func (o ControllerOptions) apply(ss *appsv1.StatefulSet) {
+	pod := &ss.Spec.Template.Spec
+	for i, c := range pod.Containers {
+		if c.Name != "manager" {
+			continue
+		}
+		if len(o.Requests) > 0 {
+			pod.Containers[i].Resources.Requests = o.Requests
+		}
+		if len(o.Limits) > 0 {
+			pod.Containers[i].Resources.Limits = o.Limits
+		}
+	}
+	pod.NodeSelector = o.NodeSelector
+	pod.Tolerations = o.Tolerations
+	pod.Affinity = o.Affinity
+	pod.PriorityClassName = o.PriorityClassName
+}
+
+func expandControllerOptions(l []interface{}) (ControllerOptions, error) {
+	o := ControllerOptions{
+		TerminationGracePeriodSeconds: defaultTerminationGracePeriodSeconds,
+	}
+	if len(l) == 0 || l[0] == nil {
+		return o, nil
+	}
+	in := l[0].(map[string]interface{})
+
+	var err error
+	if o.Requests, err = expandResourceList(in["requests"].(map[string]interface{})); err != nil {
+		return o, fmt.Errorf("controller.requests: %v", err)
+	}
+	if o.Limits, err = expandResourceList(in["limits"].(map[string]interface{})); err != nil {
+		return o, fmt.Errorf("controller.limits: %v", err)
+	}
+	if v := in["node_selector"].(map[string]interface{}); len(v) > 0 {
+		o.NodeSelector = map[string]string{}
+		for k, s := range v {
+			o.NodeSelector[k] = s.(string)
+		}
+	}
+	for _, t := range in["toleration"].([]interface{}) {
+		o.Tolerations = append(o.Tolerations, expandToleration(t.(map[string]interface{})))
+	}
+	if v := in["affinity"].(string); v != "" {
+		o.Affinity = &corev1.Affinity{}
+		if err := json.Unmarshal([]byte(v), o.Affinity); err != nil {
+			return o, fmt.Errorf("controller.affinity: %v", err)
+		}
+	}
+	o.PriorityClassName = in["priority_class_name"].(string)
+	o.TerminationGracePeriodSeconds = int64(in["termination_grace_period_seconds"].(int))
+	o.LeaderElectionFlag = in["leader_election_flag"].(string)
+	o.LeaderElectionIDEnv = in["leader_election_id_env"].(string)
+	return o, nil
+}
+
+func expandResourceList(m map[string]interface{}) (corev1.ResourceList, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	list := corev1.ResourceList{}
+	for name, v := range m {
+		q, err := resource.ParseQuantity(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity for %s: %v", name, err)
+		}
+		list[corev1.ResourceName(name)] = q
+	}
+	return list, nil
+}
+
+func expandToleration(in map[string]interface{}) corev1.Toleration {
+	t := corev1.Toleration{
+		Key:      in["key"].(string),
+		Operator: corev1.TolerationOperator(in["operator"].(string)),
+		Value:    in["value"].(string),
+		Effect:   corev1.TaintEffect(in["effect"].(string)),
+	}
+	if v := in["toleration_seconds"].(int); v > 0 {
+		seconds := int64(v)
+		t.TolerationSeconds = &seconds
+	}
+	return t
+}
+
+//flattenControllerOptions reads the settings of the controller block back from the StatefulSet.
+// The manager container only has arguments for leader election, and the variable holding
+// leaderElectionID is the one leader_election_id_env names.
+func flattenControllerOptions(ss *appsv1.StatefulSet, leaderElectionID string) []interface{} {
+	pod := ss.Spec.Template.Spec
+	out := map[string]interface{}{
+		"requests":               map[string]interface{}{},
+		"limits":                 map[string]interface{}{},
+		"node_selector":          map[string]interface{}{},
+		"toleration":             []interface{}{},
+		"affinity":               "",
+		"priority_class_name":    pod.PriorityClassName,
+		"leader_election_flag":   "",
+		"leader_election_id_env": "",
+	}
+	if pod.TerminationGracePeriodSeconds != nil {
+		out["termination_grace_period_seconds"] = int(*pod.TerminationGracePeriodSeconds)
+	}
+	for _, c := range pod.Containers {
+		if c.Name != "manager" {
+			continue
+		}
+		out["requests"] = flattenResourceList(c.Resources.Requests)
+		out["limits"] = flattenResourceList(c.Resources.Limits)
+		if len(c.Args) > 0 {
+			out["leader_election_flag"] = c.Args[0]
+		}
+		for _, e := range c.Env {
+			if leaderElectionID != "" && e.Value == leaderElectionID {
+				out["leader_election_id_env"] = e.Name
+			}
+		}
+	}
+	for k, v := range pod.NodeSelector {
+		out["node_selector"].(map[string]interface{})[k] = v
+	}
+	for _, t := range pod.Tolerations {
+		toleration := map[string]interface{}{
+			"key":                t.Key,
+			"operator":           string(t.Operator),
+			"value":              t.Value,
+			"effect":             string(t.Effect),
+			"toleration_seconds": 0,
+		}
+		if t.TolerationSeconds != nil {
+			toleration["toleration_seconds"] = int(*t.TolerationSeconds)
+		}
+		out["toleration"] = append(out["toleration"].([]interface{}), toleration)
+	}
+	if pod.Affinity != nil {
+		if b, err := json.Marshal(pod.Affinity); err == nil {
+			out["affinity"] = string(b)
+		}
+	}
+	return []interface{}{out}
+}
+
+func flattenResourceList(list corev1.ResourceList) map[string]interface{} {
+	out := map[string]interface{}{}
+	for name, q := range list {
+		out[string(name)] = q.String()
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+)
+
+func testControllerBlock() []interface{} {
+	return []interface{}{map[string]interface{}{
+		"requests":      map[string]interface{}{"cpu": "200m"},
+		"limits":        map[string]interface{}{"memory": "1Gi"},
+		"node_selector": map[string]interface{}{"node-role.kubernetes.io/system": ""},
+		"toleration": []interface{}{map[string]interface{}{
+			"key":                "CriticalAddonsOnly",
+			"operator":           "Exists",
+			"value":              "",
+			"effect":             "NoSchedule",
+			"toleration_seconds": 0,
+		}},
+		"affinity":                         `{"nodeAffinity":{"requiredDuringSchedulingIgnoredDuringExecution":{"nodeSelectorTerms":[{"matchExpressions":[{"key":"zone","operator":"In","values":["a"]}]}]}}}`,
+		"priority_class_name":              "system-cluster-critical",
+		"termination_grace_period_seconds": 60,
+		"leader_election_flag":             "",
+		"leader_election_id_env":           "",
+	}}
+}
+
+func TestExpandControllerOptions(t *testing.T) {
+	o, err := expandControllerOptions(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(defaultTerminationGracePeriodSeconds), o.TerminationGracePeriodSeconds)
+
+	o, err = expandControllerOptions(testControllerBlock())
+	assert.NoError(t, err)
+	assert.Equal(t, "200m", o.Requests.Cpu().String())
+	assert.Equal(t, "1Gi", o.Limits.Memory().String())
+	assert.Equal(t, corev1.TolerationOpExists, o.Tolerations[0].Operator)
+	assert.Nil(t, o.Tolerations[0].TolerationSeconds)
+	assert.Equal(t, "zone", o.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions[0].Key)
+	assert.Equal(t, int64(60), o.TerminationGracePeriodSeconds)
+
+	block := testControllerBlock()
+	block[0].(map[string]interface{})["requests"] = map[string]interface{}{"cpu": "lots"}
+	_, err = expandControllerOptions(block)
+	assert.Error(t, err)
+}
+
+func TestInstallKUDO_controller(t *testing.T) {
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extfake.NewSimpleClientset(),
+	}
+	controller, err := expandControllerOptions(testControllerBlock())
+	assert.NoError(t, err)
+	opts := InstallOptions{
+		KudoImage:      "kudobuilder/controller",
+		Version:        "0.14.0",
+		Namespace:      kudoinit.DefaultNamespace,
+		ServiceAccount: "kudo-manager",
+		Controller:     controller,
+	}
+
+	assert.NoError(t, installKUDO(client, opts))
+	ss, err := client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod := ss.Spec.Template.Spec
+	assert.Equal(t, "system-cluster-critical", pod.PriorityClassName)
+	assert.Equal(t, int64(60), *pod.TerminationGracePeriodSeconds)
+	assert.Equal(t, "CriticalAddonsOnly", pod.Tolerations[0].Key)
+	assert.Equal(t, "200m", pod.Containers[0].Resources.Requests.Cpu().String())
+	assert.Equal(t, flattenControllerOptions(ss, "")[0].(map[string]interface{})["priority_class_name"], "system-cluster-critical")
+
+	// later applies reconcile the existing StatefulSet
+	opts.Controller.Tolerations = nil
+	opts.Controller.Requests = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}
+	opts.Version = "0.15.0"
+	assert.NoError(t, installKUDO(client, opts))
+	ss, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod = ss.Spec.Template.Spec
+	assert.Empty(t, pod.Tolerations)
+	assert.Equal(t, "500m", pod.Containers[0].Resources.Requests.Cpu().String())
+	assert.Equal(t, "kudobuilder/controller:v0.15.0", pod.Containers[0].Image)
+}
+
+func TestSuppressEquivalentAffinity(t *testing.T) {
+	assert.True(t, suppressEquivalentAffinity("", `{"nodeAffinity":{}}`, `{ "nodeAffinity": {} }`, nil))
+	assert.False(t, suppressEquivalentAffinity("", `{"nodeAffinity":{}}`, `{"podAffinity":{}}`, nil))
+	assert.True(t, suppressEquivalentQuantity("", "100m", "0.1", nil))
+	assert.False(t, suppressEquivalentQuantity("", "100m", "1", nil))
+}
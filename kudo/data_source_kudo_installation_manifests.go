@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// the kudo_installation attributes that only matter when applying KUDO
+var installationLifecycleAttributes = []string{"allow_downgrade", "force", "delete_crds", "wait", "wait_timeout"}
+
+func dataSourceInstallationManifests() *schema.Resource {
+	s := map[string]*schema.Schema{}
+	for k, v := range resourceInstallation().Schema {
+		if !contains(installationLifecycleAttributes, k) {
+			s[k] = dataSourceSchema(v)
+		}
+	}
+	s["manifests"] = &schema.Schema{
+		Type:        schema.TypeString,
+		Computed:    true,
+		Sensitive:   true,
+		Description: "All objects as one multi-document YAML string, in the order they are installed. Requires the cert_manager webhook_tls mode, as a self_signed CA would be generated anew on every read.",
+	}
+	s["objects"] = &schema.Schema{
+		Type:        schema.TypeMap,
+		Computed:    true,
+		Sensitive:   true,
+		Elem:        &schema.Schema{Type: schema.TypeString},
+		Description: "The YAML of each object, keyed by kind, namespace and name, e.g. statefulset/kudo-system/kudo-controller-manager",
+	}
+	return &schema.Resource{
+		Read:   dataSourceInstallationManifestsRead,
+		Schema: s,
+	}
+}
+
+//dataSourceSchema returns a copy of a resource attribute that is valid in a data source
+func dataSourceSchema(s *schema.Schema) *schema.Schema {
+	c := *s
+	c.ForceNew = false
+	if r, ok := s.Elem.(*schema.Resource); ok {
+		elem := map[string]*schema.Schema{}
+		for k, v := range r.Schema {
+			elem[k] = dataSourceSchema(v)
+		}
+		c.Elem = &schema.Resource{Schema: elem}
+	}
+	return &c
+}
+
+func dataSourceInstallationManifestsRead(d *schema.ResourceData, m interface{}) error {
+	opts, err := expandInstallOptions(d)
+	if err != nil {
+		return err
+	}
+	manifests, objects, err := renderManifests(opts)
+	if err != nil {
+		return err
+	}
+	d.SetId(opts.Namespace)
+	d.Set("manifests", manifests)
+	d.Set("objects", objects)
+	return nil
+}
+
+//renderManifests returns the objects installKUDO would create as YAML, without talking to the cluster.
+// Only cert-manager webhook certificates can be rendered: a self signed CA would be generated anew
+// every time, and the Secret the controller needs would put its key in the state.
+func renderManifests(o InstallOptions) (string, map[string]string, error) {
+	if o.WebhookTLS.Mode != webhookTLSCertManager {
+		return "", nil, fmt.Errorf("kudo_installation_manifests requires webhook_tls mode %s, the %s certificate can't be rendered ahead of the installation",
+			webhookTLSCertManager, o.WebhookTLS.Mode)
+	}
+	objs := []runtime.Object{}
+	for _, step := range newInstaller(o).steps {
+		objs = append(objs, step.Resources()...)
+	}
+
+	docs := []string{}
+	objects := map[string]string{}
+	for _, obj := range objs {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return "", nil, fmt.Errorf("error rendering %T: %v", obj, err)
+		}
+		key, err := manifestKey(obj)
+		if err != nil {
+			return "", nil, err
+		}
+		docs = append(docs, string(out))
+		objects[key] = string(out)
+	}
+	return "---\n" + strings.Join(docs, "---\n"), objects, nil
+}
+
+//manifestKey identifies an object as kind/name or kind/namespace/name
+func manifestKey(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", fmt.Errorf("error rendering %T: %v", obj, err)
+	}
+	kind := strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind)
+	if accessor.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", kind, accessor.GetName()), nil
+	}
+	return fmt.Sprintf("%s/%s/%s", kind, accessor.GetNamespace(), accessor.GetName()), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataSourceInstallationManifestsRead(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, dataSourceInstallationManifests().Schema, map[string]interface{}{
+		"namespace":       "platform",
+		"registry_mirror": "registry.example.com",
+		"controller": []interface{}{map[string]interface{}{
+			"priority_class_name": "system-cluster-critical",
+		}},
+		"webhook_tls": []interface{}{map[string]interface{}{
+			"mode":        webhookTLSCertManager,
+			"issuer_name": "kudo-ca",
+		}},
+	})
+	assert.NoError(t, dataSourceInstallationManifestsRead(d, Config{}))
+	assert.Equal(t, "platform", d.Id())
+
+	objects := d.Get("objects").(map[string]interface{})
+	for _, key := range []string{
+		"customresourcedefinition/instances.kudo.dev",
+		"serviceaccount/platform/kudo-manager",
+		"clusterrolebinding/kudo-manager-rolebinding",
+		"mutatingwebhookconfiguration/kudo-manager-instance-admission-webhook-config",
+		"certificate/platform/kudo-webhook-server-certificate",
+		"service/platform/kudo-controller-manager-service",
+		"statefulset/platform/kudo-controller-manager",
+	} {
+		assert.Contains(t, objects, key)
+	}
+	// custom namespaces are expected to exist, and cert-manager issues the secret
+	assert.NotContains(t, objects, "namespace/platform")
+	assert.NotContains(t, objects, "secret/platform/kudo-webhook-server-secret")
+
+	ss := objects["statefulset/platform/kudo-controller-manager"].(string)
+	assert.Contains(t, ss, "image: registry.example.com/kudobuilder/controller:v0.14.0")
+	assert.Contains(t, ss, "priorityClassName: system-cluster-critical")
+	assert.Contains(t, objects["mutatingwebhookconfiguration/kudo-manager-instance-admission-webhook-config"], "cert-manager.io/inject-ca-from: platform/kudo-webhook-server-certificate")
+
+	manifests := d.Get("manifests").(string)
+	assert.Equal(t, len(objects), strings.Count(manifests, "---\n"))
+	assert.True(t, strings.HasPrefix(manifests, "---\napiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition"))
+}
+
+func TestDataSourceInstallationManifestsSelfSigned(t *testing.T) {
+	s := dataSourceInstallationManifests().Schema
+	assert.True(t, s["manifests"].Sensitive)
+	assert.True(t, s["objects"].Sensitive)
+
+	// the default self_signed mode can't be rendered
+	for _, raw := range []map[string]interface{}{
+		{},
+		{"webhook_tls": []interface{}{map[string]interface{}{"mode": webhookTLSSelfSigned}}},
+	} {
+		d := schema.TestResourceDataRaw(t, s, raw)
+		err := dataSourceInstallationManifestsRead(d, Config{})
+		assert.EqualError(t, err, "kudo_installation_manifests requires webhook_tls mode cert_manager, the self_signed certificate can't be rendered ahead of the installation")
+		assert.Equal(t, "", d.Id())
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+)
+
+// The provider's default_labels and default_annotations are merged into the labels and
+// annotations of each resource. The resource's own keys are kept in labels and annotations,
+// the merged result is in labels_all and annotations_all, so that the plan shows where a
+// change comes from. Keys set by KUDO are left alone, unless a resource sets them.
+
+// labels and annotations with this prefix are set by KUDO itself
+const kudoMetadataPrefix = "kudo.dev/"
+
+//mergeMetadata returns the provider defaults overridden by the resource's own keys
+func mergeMetadata(defaults, own map[string]string) map[string]string {
+	merged := map[string]string{}
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range own {
+		merged[k] = v
+	}
+	return merged
+}
+
+//managedMetadata drops the keys KUDO set from actual, unless they are in merged
+func managedMetadata(actual, merged map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range actual {
+		if _, ok := merged[k]; ok || !strings.HasPrefix(k, kudoMetadataPrefix) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+//ownMetadata returns the keys of all that belong to the resource: the ones it sets itself,
+// and the ones that don't come from the provider defaults
+func ownMetadata(all, defaults, own map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range all {
+		_, isOwn := own[k]
+		if d, isDefault := defaults[k]; isOwn || !isDefault || d != v {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+//expandStringMap converts a TypeMap of strings
+func expandStringMap(m map[string]interface{}) map[string]string {
+	out := map[string]string{}
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			out[k] = s
+		}
+	}
+	return out
+}
+
+//metadataPatch returns the metadata of a JSON merge patch that sets labels and annotations,
+// and removes the keys that were in the old ones only
+func metadataPatch(labels, oldLabels, annotations, oldAnnotations map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"labels":      mapPatch(labels, oldLabels),
+		"annotations": mapPatch(annotations, oldAnnotations),
+	}
+}
+
+func mapPatch(m, old map[string]string) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for k := range old {
+		patch[k] = nil
+	}
+	for k, v := range m {
+		patch[k] = v
+	}
+	return patch
+}
+
+//marshalMetadataPatch returns a JSON merge patch for the metadata only
+func marshalMetadataPatch(labels, oldLabels, annotations, oldAnnotations map[string]string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": metadataPatch(labels, oldLabels, annotations, oldAnnotations),
+	})
+}
+
+//defaultNamespace fills in the provider's default_namespace for a new resource that doesn't set one
+func defaultNamespace(keys ...string) schema.CustomizeDiffFunc {
+	return func(d *schema.ResourceDiff, m interface{}) error {
+		if d.Id() != "" {
+			return nil
+		}
+		for _, key := range keys {
+			if v, ok := d.GetOk(key); ok && v.(string) != "" {
+				continue
+			}
+			if err := d.SetNew(key, m.(Config).DefaultNamespace); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+//mergeDefaultMetadata plans labels_all and annotations_all from the resource's labels and
+// annotations and the provider defaults
+func mergeDefaultMetadata(d *schema.ResourceDiff, m interface{}) error {
+	config := m.(Config)
+	for _, key := range []string{"labels", "annotations"} {
+		if !d.NewValueKnown(key) {
+			if err := d.SetNewComputed(key + "_all"); err != nil {
+				return err
+			}
+			continue
+		}
+		defaults := config.DefaultLabels
+		if key == "annotations" {
+			defaults = config.DefaultAnnotations
+		}
+		merged := mergeMetadata(defaults, expandStringMap(d.Get(key).(map[string]interface{})))
+		old := expandStringMap(d.Get(key + "_all").(map[string]interface{}))
+		if d.Id() != "" && equalStringMaps(merged, old) {
+			continue
+		}
+		if err := d.SetNew(key+"_all", merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//setMetadata stores the labels and annotations of an object in the state
+func setMetadata(d *schema.ResourceData, config Config, labels, annotations map[string]string) {
+	ownLabels := expandStringMap(d.Get("labels").(map[string]interface{}))
+	allLabels := managedMetadata(labels, mergeMetadata(config.DefaultLabels, ownLabels))
+	d.Set("labels", ownMetadata(allLabels, config.DefaultLabels, ownLabels))
+	d.Set("labels_all", allLabels)
+
+	ownAnnotations := expandStringMap(d.Get("annotations").(map[string]interface{}))
+	allAnnotations := managedMetadata(annotations, mergeMetadata(config.DefaultAnnotations, ownAnnotations))
+	d.Set("annotations", ownMetadata(allAnnotations, config.DefaultAnnotations, ownAnnotations))
+	d.Set("annotations_all", allAnnotations)
+}
+
+//resourceMetadata returns the merged labels and annotations the object should have
+func resourceMetadata(d *schema.ResourceData, config Config) (map[string]string, map[string]string) {
+	labels := mergeMetadata(config.DefaultLabels, expandStringMap(d.Get("labels").(map[string]interface{})))
+	annotations := mergeMetadata(config.DefaultAnnotations, expandStringMap(d.Get("annotations").(map[string]interface{})))
+	return labels, annotations
+}
+
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnMetadata(t *testing.T) {
+	defaults := map[string]string{"team": "data", "env": "prod"}
+	own := map[string]string{"env": "staging", "app": "kafka"}
+	merged := mergeMetadata(defaults, own)
+	assert.Equal(t, map[string]string{"team": "data", "env": "staging", "app": "kafka"}, merged)
+
+	// KUDO's own keys are ignored, keys that drifted from the defaults belong to the resource
+	actual := map[string]string{"team": "platform", "env": "staging", "app": "kafka", "kudo.dev/operator": "kafka"}
+	all := managedMetadata(actual, merged)
+	assert.Equal(t, map[string]string{"team": "platform", "env": "staging", "app": "kafka"}, all)
+	assert.Equal(t, map[string]string{"team": "platform", "env": "staging", "app": "kafka"}, ownMetadata(all, defaults, own))
+
+	all["team"] = "data"
+	assert.Equal(t, map[string]string{"env": "staging", "app": "kafka"}, ownMetadata(all, defaults, own))
+}
+
+func TestMetadataPatch(t *testing.T) {
+	patch, err := marshalMetadataPatch(
+		map[string]string{"team": "data"}, map[string]string{"team": "platform", "app": "kafka"},
+		map[string]string{}, map[string]string{},
+	)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata": {"labels": {"team": "data", "app": null}, "annotations": {}}}`, string(patch))
+}
+
+func TestSetMetadata(t *testing.T) {
+	config := Config{
+		DefaultLabels:      map[string]string{"team": "data"},
+		DefaultAnnotations: map[string]string{"owner": "alice"},
+	}
+	d := schema.TestResourceDataRaw(t, resourceInstance().Schema, map[string]interface{}{
+		"name":                  "pipes",
+		"operator_version_name": "kafka-1.2.0",
+		"labels":                map[string]interface{}{"app": "kafka"},
+	})
+	labels, annotations := resourceMetadata(d, config)
+	assert.Equal(t, map[string]string{"team": "data", "app": "kafka"}, labels)
+	assert.Equal(t, map[string]string{"owner": "alice"}, annotations)
+
+	labels["kudo.dev/operator"] = "kafka"
+	annotations["kudo.dev/last-applied-instance-state"] = "{}"
+	setMetadata(d, config, labels, annotations)
+	assert.Equal(t, map[string]interface{}{"app": "kafka"}, d.Get("labels"))
+	assert.Equal(t, map[string]interface{}{"app": "kafka", "team": "data"}, d.Get("labels_all"))
+	assert.Equal(t, map[string]interface{}{}, d.Get("annotations"))
+	assert.Equal(t, map[string]interface{}{"owner": "alice"}, d.Get("annotations_all"))
+}
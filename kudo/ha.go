@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/manager"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/verifier"
+)
+
+const (
+	// the node labels the controller replicas are spread across
+	zoneTopologyKey = "topology.kubernetes.io/zone"
+	hostTopologyKey = "kubernetes.io/hostname"
+
+	// the annotation controller-runtime records the leader of an election in
+	leaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+)
+
+//controllerReplicas returns the number of controller replicas, at least one
+func (o InstallOptions) controllerReplicas() int32 {
+	if o.ControllerReplicas < 1 {
+		return 1
+	}
+	return o.ControllerReplicas
+}
+
+//highlyAvailable is true when more than one controller replica runs
+func (o InstallOptions) highlyAvailable() bool {
+	return o.controllerReplicas() > 1
+}
+
+//verifyHA fails for more than one replica without leader election. The KUDO managers up to 0.14
+// don't elect a leader, so all replicas would reconcile the same Instances. A controller image that
+// can has to be told how to turn it on, with the leader_election_flag or leader_election_id_env of
+// the controller block.
+func (o InstallOptions) verifyHA() error {
+	if !o.highlyAvailable() {
+		return nil
+	}
+	if o.LeaderElectionID == "" {
+		return fmt.Errorf("controller_replicas > 1 requires leader_election_id")
+	}
+	if o.Controller.LeaderElectionFlag == "" && o.Controller.LeaderElectionIDEnv == "" {
+		return fmt.Errorf("controller_replicas > 1 requires a controller image that elects a leader, and controller.leader_election_flag or controller.leader_election_id_env to turn it on, the KUDO %s manager doesn't elect one", o.Version)
+	}
+	return nil
+}
+
+//verifyHADiff rejects more than one replica at plan time, unless the settings it depends on are
+// only known during apply
+func verifyHADiff(d *schema.ResourceDiff, m interface{}) error {
+	for _, key := range []string{"controller_replicas", "leader_election_id", "controller"} {
+		if !d.NewValueKnown(key) {
+			return nil
+		}
+	}
+	o := InstallOptions{
+		Version:            d.Get("kudo_version").(string),
+		ControllerReplicas: int32(d.Get("controller_replicas").(int)),
+		LeaderElectionID:   d.Get("leader_election_id").(string),
+	}
+	if l := d.Get("controller").([]interface{}); len(l) > 0 && l[0] != nil {
+		in := l[0].(map[string]interface{})
+		o.Controller.LeaderElectionFlag = in["leader_election_flag"].(string)
+		o.Controller.LeaderElectionIDEnv = in["leader_election_id_env"].(string)
+	}
+	return o.verifyHA()
+}
+
+//applyHA sets the replicas of the manager StatefulSet, turns on leader election in the manager
+// container the way the controller block says, and spreads the replicas across zones and nodes
+func (o InstallOptions) applyHA(ss *appsv1.StatefulSet) {
+	replicas := o.controllerReplicas()
+	ss.Spec.Replicas = &replicas
+	if o.LeaderElectionID != "" {
+		pod := &ss.Spec.Template.Spec
+		for i, c := range pod.Containers {
+			if c.Name != "manager" {
+				continue
+			}
+			if o.Controller.LeaderElectionFlag != "" {
+				pod.Containers[i].Args = append(pod.Containers[i].Args, o.Controller.LeaderElectionFlag)
+			}
+			if o.Controller.LeaderElectionIDEnv != "" {
+				pod.Containers[i].Env = append(pod.Containers[i].Env, corev1.EnvVar{Name: o.Controller.LeaderElectionIDEnv, Value: o.LeaderElectionID})
+			}
+		}
+	}
+	if !o.highlyAvailable() {
+		return
+	}
+	// scheduling anyway keeps single zone clusters working
+	selector := &metav1.LabelSelector{MatchLabels: manager.GenerateLabels()}
+	ss.Spec.Template.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+		{MaxSkew: 1, TopologyKey: zoneTopologyKey, WhenUnsatisfiable: corev1.ScheduleAnyway, LabelSelector: selector},
+		{MaxSkew: 1, TopologyKey: hostTopologyKey, WhenUnsatisfiable: corev1.ScheduleAnyway, LabelSelector: selector},
+	}
+}
+
+// Ensure IF is implemented
+var _ kudoinit.Step = &disruptionBudgetStep{}
+
+//disruptionBudgetStep keeps all but one controller replica running during node drains. The budget
+// only exists with more than one replica, as it would block draining the node of a single one.
+type disruptionBudgetStep struct {
+	namespace string
+	replicas  int32
+}
+
+func newDisruptionBudgetStep(o InstallOptions) *disruptionBudgetStep {
+	return &disruptionBudgetStep{namespace: o.Namespace, replicas: o.controllerReplicas()}
+}
+
+func (p *disruptionBudgetStep) String() string {
+	return "pod disruption budget"
+}
+
+//PreInstallVerify has nothing to verify
+func (p *disruptionBudgetStep) PreInstallVerify(client *kube.Client, result *verifier.Result) error {
+	return nil
+}
+
+//Install creates or updates the budget, or removes it when only one replica is left
+func (p *disruptionBudgetStep) Install(client *kube.Client) error {
+	budgets := client.KubeClient.PolicyV1beta1().PodDisruptionBudgets(p.namespace)
+	if p.replicas <= 1 {
+		err := budgets.Delete(kudoinit.DefaultManagerName, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("pod disruption budget: %v", err)
+		}
+		return nil
+	}
+	budget := p.budget()
+	existing, err := budgets.Get(budget.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = budgets.Create(budget)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("pod disruption budget: %v", err)
+	}
+	log.Printf("[DEBUG] Updating KUDO controller PodDisruptionBudget %s/%s", p.namespace, budget.Name)
+	existing.Spec = budget.Spec
+	_, err = budgets.Update(existing)
+	return err
+}
+
+//Resources returns the budget, if there is one
+func (p *disruptionBudgetStep) Resources() []runtime.Object {
+	if p.replicas <= 1 {
+		return []runtime.Object{}
+	}
+	return []runtime.Object{p.budget()}
+}
+
+func (p *disruptionBudgetStep) budget() *policyv1beta1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	return &policyv1beta1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PodDisruptionBudget",
+			APIVersion: "policy/v1beta1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      kudoinit.DefaultManagerName,
+			Namespace: p.namespace,
+			Labels:    manager.GenerateLabels(),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       &metav1.LabelSelector{MatchLabels: manager.GenerateLabels()},
+		},
+	}
+}
+
+//leaderReadinessCheck waits for a ready controller pod to hold the leader election lock
+func leaderReadinessCheck(client *kube.Client, namespace, id string) readinessCheck {
+	return readinessCheck{
+		description: fmt.Sprintf("a ready KUDO controller to hold the leader election lock %s/%s", namespace, id),
+		lw: namedListWatch(id,
+			func(o metav1.ListOptions) (runtime.Object, error) {
+				return client.KubeClient.CoreV1().ConfigMaps(namespace).List(o)
+			},
+			func(o metav1.ListOptions) (watch.Interface, error) {
+				return client.KubeClient.CoreV1().ConfigMaps(namespace).Watch(o)
+			}),
+		objType: &corev1.ConfigMap{},
+		ready: func(obj runtime.Object) bool {
+			leader := leaderPod(obj.(*corev1.ConfigMap))
+			if leader == "" {
+				return false
+			}
+			pod, err := client.KubeClient.CoreV1().Pods(namespace).Get(leader, metav1.GetOptions{})
+			if err != nil {
+				log.Printf("[DEBUG] Can't get leading KUDO controller %s: %v", leader, err)
+				return false
+			}
+			log.Printf("[DEBUG] KUDO controller %s is the leader", leader)
+			return podReady(pod)
+		},
+	}
+}
+
+//leaderPod returns the name of the pod holding a controller-runtime leader election lock. The holder
+// identity is the hostname of the pod, followed by a random suffix.
+func leaderPod(cm *corev1.ConfigMap) string {
+	record := struct {
+		HolderIdentity string `json:"holderIdentity"`
+	}{}
+	if err := json.Unmarshal([]byte(cm.Annotations[leaderAnnotation]), &record); err != nil {
+		return ""
+	}
+	return strings.SplitN(record.HolderIdentity, "_", 2)[0]
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+//statefulSetAvailable is true once the StatefulSet has seen its latest spec and one replica is ready.
+// Other replicas may be unavailable, e.g. while their node is drained.
+func statefulSetAvailable(ss *appsv1.StatefulSet) bool {
+	return ss.Status.ObservedGeneration >= ss.Generation && ss.Status.ReadyReplicas > 0
+}
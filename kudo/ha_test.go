@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/stretchr/testify/assert"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+)
+
+func TestInstallKUDO_ha(t *testing.T) {
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extfake.NewSimpleClientset(),
+	}
+	opts := InstallOptions{
+		KudoImage:          "kudobuilder/controller",
+		Version:            "0.14.0",
+		Namespace:          kudoinit.DefaultNamespace,
+		ServiceAccount:     "kudo-manager",
+		ControllerReplicas: 3,
+	}
+	assert.EqualError(t, opts.verifyHA(), "controller_replicas > 1 requires leader_election_id")
+
+	opts.LeaderElectionID = "kudo-leader"
+	assert.EqualError(t, opts.verifyHA(), "controller_replicas > 1 requires a controller image that elects a leader, and controller.leader_election_flag or controller.leader_election_id_env to turn it on, the KUDO 0.14.0 manager doesn't elect one")
+
+	opts.ImageOverride = "registry.example.com/kudo-controller:ha"
+	opts.Controller.LeaderElectionFlag = "--enable-leader-election"
+	opts.Controller.LeaderElectionIDEnv = "LEADER_ELECTION_ID"
+	assert.NoError(t, opts.verifyHA())
+	assert.NoError(t, installKUDO(client, opts))
+	ss, err := client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), *ss.Spec.Replicas)
+	manager := ss.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "manager", manager.Name)
+	assert.Equal(t, []string{"--enable-leader-election"}, manager.Args)
+	assert.Contains(t, manager.Env, corev1.EnvVar{Name: "LEADER_ELECTION_ID", Value: "kudo-leader"})
+	controller := flattenControllerOptions(ss, opts.LeaderElectionID)[0].(map[string]interface{})
+	assert.Equal(t, "--enable-leader-election", controller["leader_election_flag"])
+	assert.Equal(t, "LEADER_ELECTION_ID", controller["leader_election_id_env"])
+	assert.Len(t, ss.Spec.Template.Spec.TopologySpreadConstraints, 2)
+	assert.Equal(t, zoneTopologyKey, ss.Spec.Template.Spec.TopologySpreadConstraints[0].TopologyKey)
+	pdb, err := client.KubeClient.PolicyV1beta1().PodDisruptionBudgets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pdb.Spec.MaxUnavailable.IntValue())
+
+	// scaling back down removes the budget, which would block draining the only replica
+	opts.ControllerReplicas = 1
+	assert.NoError(t, installKUDO(client, opts))
+	ss, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *ss.Spec.Replicas)
+	assert.Empty(t, ss.Spec.Template.Spec.TopologySpreadConstraints)
+	_, err = client.KubeClient.PolicyV1beta1().PodDisruptionBudgets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestInstallationDiff_ha(t *testing.T) {
+	r := resourceInstallation()
+	diff := func(config map[string]interface{}) error {
+		_, err := r.Diff(nil, terraform.NewResourceConfigRaw(config), Config{})
+		return err
+	}
+	assert.NoError(t, diff(map[string]interface{}{}))
+	err := diff(map[string]interface{}{"controller_replicas": 3, "leader_election_id": "kudo-leader"})
+	assert.EqualError(t, err, "controller_replicas > 1 requires a controller image that elects a leader, and controller.leader_election_flag or controller.leader_election_id_env to turn it on, the KUDO 0.14.0 manager doesn't elect one")
+	assert.NoError(t, diff(map[string]interface{}{
+		"controller_replicas": 3,
+		"leader_election_id":  "kudo-leader",
+		"image_override":      "registry.example.com/kudo-controller:ha",
+		"controller": []interface{}{map[string]interface{}{
+			"leader_election_flag": "--enable-leader-election",
+		}},
+	}))
+}
+
+func TestWaitForReadiness_leader(t *testing.T) {
+	opts := InstallOptions{Namespace: kudoinit.DefaultNamespace, ControllerReplicas: 3, LeaderElectionID: "kudo-leader"}
+	three := int32(3)
+	// one replica is ready, the others are on a node that is drained
+	ss := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: kudoinit.DefaultManagerName, Namespace: opts.Namespace},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &three},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1, CurrentRevision: "a", UpdateRevision: "b"},
+	}
+	ep := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: kudoinit.DefaultServiceName, Namespace: opts.Namespace},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+	lock := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        opts.LeaderElectionID,
+			Namespace:   opts.Namespace,
+			Annotations: map[string]string{leaderAnnotation: `{"holderIdentity":"kudo-controller-manager-1_0b5f"}`},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kudo-controller-manager-1", Namespace: opts.Namespace},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionFalse}},
+		},
+	}
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(ss, ep, lock, pod),
+		ExtClient: extfake.NewSimpleClientset(
+			testCRD("operators.kudo.dev", true),
+			testCRD("operatorversions.kudo.dev", true),
+			testCRD("instances.kudo.dev", true),
+		),
+	}
+	assert.Equal(t, "kudo-controller-manager-1", leaderPod(lock))
+
+	// the leader isn't ready yet
+	err := waitForReadiness(kudoReadinessChecks(client, opts), time.Second)
+	assert.EqualError(t, err, "timed out after 1s waiting for a ready KUDO controller to hold the leader election lock kudo-system/kudo-leader")
+
+	pod.Status.Conditions[0].Status = corev1.ConditionTrue
+	_, err = client.KubeClient.CoreV1().Pods(opts.Namespace).Update(pod)
+	assert.NoError(t, err)
+	assert.NoError(t, waitForReadiness(kudoReadinessChecks(client, opts), time.Second))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/crd"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/verifier"
+)
+
+//InstallOptions captures the settings used to install the KUDO controller
+type InstallOptions struct {
+	KudoImage      string
+	Version        string
+	Wait           bool
+	WaitTimeout    int
+	ServiceAccount string
+	CRDsOnly       bool
+	Namespace      string
+	WebhookTLS     WebhookTLSOptions
+	Controller     ControllerOptions
+
+	ControllerReplicas int32
+	LeaderElectionID   string
+
+	ImagePullPolicy  string
+	ImagePullSecrets []string
+	ImageOverride    string
+	RegistryMirror   string
+}
+
+//ToKUDOOpts returns a KUDO Options object for installing KUDO
+func (o InstallOptions) ToKUDOOpts() kudoinit.Options {
+	gracePeriod := o.Controller.TerminationGracePeriodSeconds
+	if gracePeriod == 0 {
+		gracePeriod = defaultTerminationGracePeriodSeconds
+	}
+	opts := kudoinit.Options{
+		Version:                       o.Version,
+		Namespace:                     o.Namespace,
+		TerminationGracePeriodSeconds: gracePeriod,
+		Image:                         o.ControllerImage(),
+		ServiceAccount:                o.ServiceAccount,
+		ImagePullPolicy:               o.imagePullPolicy(),
+		SelfSignedWebhookCA:           o.WebhookTLS.Mode != webhookTLSCertManager,
+	}
+	return opts
+}
+
+//ControllerImage returns the image reference the controller runs. An ImageOverride is used as is,
+// otherwise the image is built from KudoImage and Version and moved to the RegistryMirror.
+func (o InstallOptions) ControllerImage() string {
+	if o.ImageOverride != "" {
+		return o.ImageOverride
+	}
+	return mirrorImage(fmt.Sprintf("%v:v%v", o.KudoImage, o.Version), o.RegistryMirror)
+}
+
+func (o InstallOptions) imagePullPolicy() string {
+	if o.ImagePullPolicy == "" {
+		return "Always"
+	}
+	return o.ImagePullPolicy
+}
+
+//mirrorImage replaces the registry of an image reference with the mirror prefix
+func mirrorImage(ref, mirror string) string {
+	if mirror == "" {
+		return ref
+	}
+	return strings.TrimSuffix(mirror, "/") + "/" + stripRegistry(ref)
+}
+
+//unmirrorImage reverts mirrorImage, returning false if ref isn't pulled from the mirror
+func unmirrorImage(ref, mirror string) (string, bool) {
+	if mirror == "" {
+		return ref, true
+	}
+	prefix := strings.TrimSuffix(mirror, "/") + "/"
+	if !strings.HasPrefix(ref, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(ref, prefix), true
+}
+
+//stripRegistry removes the registry host from an image reference. Like docker, the first
+// path component is a registry if it contains a dot or a port, or is localhost.
+func stripRegistry(ref string) string {
+	i := strings.Index(ref, "/")
+	if i < 0 {
+		return ref
+	}
+	host := ref[:i]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		return ref[i+1:]
+	}
+	return ref
+}
+
+//installKUDO installs the KUDO CRDs and controller described by the options and
+// waits for it to become ready when Wait is set
+func installKUDO(client *kube.Client, o InstallOptions) error {
+	log.Printf("[DEBUG] Running install")
+	opts := o.ToKUDOOpts()
+	log.Printf("[DEBUG] KUDO Opts: %+v", opts)
+
+	installer := newInstaller(o)
+	err := installer.verify(client)
+	if err != nil {
+		return err
+	}
+
+	err = installer.Install(client)
+	if err != nil {
+		log.Printf("[ERROR] [KUDO] Error installing KUDO: %+v", err)
+		return err
+	}
+
+	timeout := time.Duration(o.WaitTimeout) * time.Second
+	if o.CRDsOnly {
+		// There is no controller to wait for, but the CRDs have to be
+		// Established before any Operators can be created
+		return waitForReadiness(crdReadinessChecks(client), timeout)
+	}
+	if !o.Wait {
+		return nil
+	}
+	return waitForReadiness(kudoReadinessChecks(client, o), timeout)
+}
+
+//kudoInstaller runs the same steps as `kudo init`, except that the webhook step is replaced
+// when its certificate is issued by cert-manager
+type kudoInstaller struct {
+	steps []kudoinit.Step
+}
+
+func newInstaller(o InstallOptions) *kudoInstaller {
+	if o.CRDsOnly {
+		return &kudoInstaller{
+			steps: []kudoinit.Step{crd.NewInitializer()},
+		}
+	}
+
+	opts := o.ToKUDOOpts()
+	var webhook kudoinit.Step = prereq.NewWebHookInitializer(opts)
+	if o.WebhookTLS.Mode == webhookTLSCertManager {
+		webhook = newCertManagerWebhook(opts, o.WebhookTLS)
+	}
+	return &kudoInstaller{
+		steps: []kudoinit.Step{
+			crd.NewInitializer(),
+			prereq.NewNamespaceInitializer(opts),
+			prereq.NewServiceAccountInitializer(opts),
+			webhook,
+			newManagerStep(o),
+			newDisruptionBudgetStep(o),
+		},
+	}
+}
+
+//PreInstallVerify collects the problems that would make the installation fail
+func (i *kudoInstaller) PreInstallVerify(client *kube.Client, result *verifier.Result) error {
+	for _, step := range i.steps {
+		if err := step.PreInstallVerify(client, result); err != nil {
+			return fmt.Errorf("error verifying install step %s: %v", step, err)
+		}
+	}
+	return nil
+}
+
+//verify runs PreInstallVerify and turns the errors it found into one error. Warnings are only logged.
+func (i *kudoInstaller) verify(client *kube.Client) error {
+	result := verifier.NewResult()
+	if err := i.PreInstallVerify(client, &result); err != nil {
+		return err
+	}
+	for _, w := range result.Warnings {
+		log.Printf("[WARN] [KUDO] %s", w)
+	}
+	if !result.IsValid() {
+		return fmt.Errorf("KUDO can't be installed:\n%s", result.ErrorsAsString())
+	}
+	return nil
+}
+
+//Install runs every step. The steps tolerate existing objects, so this can be re-run.
+func (i *kudoInstaller) Install(client *kube.Client) error {
+	for _, step := range i.steps {
+		log.Printf("[DEBUG] Installing %s", step)
+		if err := step.Install(client); err != nil {
+			return fmt.Errorf("%s: %v", step, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+)
+
+func TestInstallKUDO_crdsOnly(t *testing.T) {
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient: extfake.NewSimpleClientset(
+			testCRD("operators.kudo.dev", true),
+			testCRD("operatorversions.kudo.dev", true),
+			testCRD("instances.kudo.dev", true),
+		),
+	}
+	opts := InstallOptions{
+		KudoImage:   "kudobuilder/controller",
+		Version:     "0.14.0",
+		Namespace:   kudoinit.DefaultNamespace,
+		CRDsOnly:    true,
+		WaitTimeout: 5,
+	}
+
+	err := installKUDO(client, opts)
+	assert.NoError(t, err)
+
+	// the manager is skipped
+	_, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.KubeClient.CoreV1().Namespaces().Get(opts.Namespace, metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestControllerImage(t *testing.T) {
+	tests := []struct {
+		opts  InstallOptions
+		image string
+	}{
+		{InstallOptions{KudoImage: "kudobuilder/controller", Version: "0.14.0"}, "kudobuilder/controller:v0.14.0"},
+		{InstallOptions{KudoImage: "kudobuilder/controller", Version: "0.14.0", RegistryMirror: "mirror.example.com/dockerhub/"}, "mirror.example.com/dockerhub/kudobuilder/controller:v0.14.0"},
+		{InstallOptions{KudoImage: "docker.io/kudobuilder/controller", Version: "0.14.0", RegistryMirror: "mirror.example.com"}, "mirror.example.com/kudobuilder/controller:v0.14.0"},
+		{InstallOptions{KudoImage: "localhost:5000/controller", Version: "0.14.0", RegistryMirror: "mirror:5000"}, "mirror:5000/controller:v0.14.0"},
+		{InstallOptions{KudoImage: "kudobuilder/controller", Version: "0.14.0", RegistryMirror: "mirror.example.com", ImageOverride: "registry.example.com/kudo@sha256:abcd"}, "registry.example.com/kudo@sha256:abcd"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.image, tt.opts.ControllerImage())
+	}
+
+	ref, ok := unmirrorImage("mirror.example.com/kudobuilder/controller:v0.14.0", "mirror.example.com/")
+	assert.True(t, ok)
+	assert.Equal(t, "kudobuilder/controller:v0.14.0", ref)
+	_, ok = unmirrorImage("kudobuilder/controller:v0.14.0", "mirror.example.com")
+	assert.False(t, ok)
+}
+
+func TestInstallKUDO_imagePull(t *testing.T) {
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extfake.NewSimpleClientset(),
+	}
+	opts := InstallOptions{
+		KudoImage:        "kudobuilder/controller",
+		Version:          "0.14.0",
+		Namespace:        kudoinit.DefaultNamespace,
+		ServiceAccount:   "kudo-manager",
+		ImagePullPolicy:  "IfNotPresent",
+		ImagePullSecrets: []string{"mirror-credentials"},
+		RegistryMirror:   "mirror.example.com",
+	}
+
+	assert.NoError(t, installKUDO(client, opts))
+	ss, err := client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	pod := ss.Spec.Template.Spec
+	assert.Equal(t, "mirror.example.com/kudobuilder/controller:v0.14.0", pod.Containers[0].Image)
+	assert.Equal(t, corev1.PullIfNotPresent, pod.Containers[0].ImagePullPolicy)
+	assert.Equal(t, []corev1.LocalObjectReference{{Name: "mirror-credentials"}}, pod.ImagePullSecrets)
+}
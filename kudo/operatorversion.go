@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/apis/kudo/v1beta1"
+	"github.com/kudobuilder/kudo/pkg/client/clientset/versioned"
+	"github.com/kudobuilder/kudo/pkg/engine/renderer"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/packages"
+)
+
+//applyPackage creates the Operator and OperatorVersion of pkg, or updates them when their contents
+// changed. An OperatorVersion is only updated when the change doesn't break the Instances using it.
+func applyPackage(kudoClient versioned.Interface, pkg *packages.Package, namespace string) error {
+	if err := applyOperator(kudoClient, pkg.Resources.Operator, namespace); err != nil {
+		return err
+	}
+	return applyOperatorVersion(kudoClient, pkg.Resources.OperatorVersion, namespace)
+}
+
+func applyOperator(kudoClient versioned.Interface, o *v1beta1.Operator, namespace string) error {
+	operators := kudoClient.KudoV1beta1().Operators(namespace)
+	existing, err := operators.Get(o.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := operators.Create(o); err != nil {
+			log.Printf("[KUDO] [%v] Error installing Operator: %v", o.Name, err)
+			return fmt.Errorf("installing Operator: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting Operator %s: %v", o.Name, err)
+	}
+	if equality.Semantic.DeepEqual(existing.Spec, o.Spec) && containsMetadata(existing.ObjectMeta, o.ObjectMeta) {
+		log.Printf("[DEBUG] Operator %v is up to date", o.Name)
+		return nil
+	}
+	log.Printf("[KUDO] Updating Operator %v", o.Name)
+	existing.Spec = o.Spec
+	existing.Labels = mergeMetadata(existing.Labels, o.Labels)
+	existing.Annotations = mergeMetadata(existing.Annotations, o.Annotations)
+	if _, err := operators.Update(existing); err != nil {
+		return fmt.Errorf("error updating Operator %s: %v", o.Name, err)
+	}
+	return nil
+}
+
+func applyOperatorVersion(kudoClient versioned.Interface, ov *v1beta1.OperatorVersion, namespace string) error {
+	versions := kudoClient.KudoV1beta1().OperatorVersions(namespace)
+	existing, err := versions.Get(ov.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		if _, err := versions.Create(ov); err != nil {
+			log.Printf("[KUDO] [%v] Error installing OperatorVersion: %v", ov.Name, err)
+			return fmt.Errorf("installing OperatorVersion: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting OperatorVersion %s: %v", ov.Name, err)
+	}
+	specChanged := !equality.Semantic.DeepEqual(existing.Spec, ov.Spec)
+	if !specChanged && containsMetadata(existing.ObjectMeta, ov.ObjectMeta) {
+		log.Printf("[DEBUG] OperatorVersion %v is up to date", ov.Name)
+		return nil
+	}
+	if specChanged {
+		instances, err := kudoClient.KudoV1beta1().Instances(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("error listing the Instances of OperatorVersion %s: %v", ov.Name, err)
+		}
+		problems := []string{}
+		for _, i := range instances.Items {
+			if i.Spec.OperatorVersion.Name != ov.Name {
+				continue
+			}
+			for _, p := range breakingChanges(existing, ov, &i) {
+				problems = append(problems, fmt.Sprintf("Instance %s/%s: %s", i.Namespace, i.Name, p))
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("the changes to OperatorVersion %s would break the Instances using it, release the package as a new operator version instead:\n  %s",
+				ov.Name, strings.Join(problems, "\n  "))
+		}
+	}
+	log.Printf("[KUDO] Updating OperatorVersion %v", ov.Name)
+	existing.Spec = ov.Spec
+	existing.Labels = mergeMetadata(existing.Labels, ov.Labels)
+	existing.Annotations = mergeMetadata(existing.Annotations, ov.Annotations)
+	if _, err := versions.Update(existing); err != nil {
+		return fmt.Errorf("error updating OperatorVersion %s: %v", ov.Name, err)
+	}
+	return nil
+}
+
+//containsMetadata is true when existing has all labels and annotations of obj
+func containsMetadata(existing, obj metav1.ObjectMeta) bool {
+	return equalStringMaps(mergeMetadata(existing.Labels, obj.Labels), existing.Labels) &&
+		equalStringMaps(mergeMetadata(existing.Annotations, obj.Annotations), existing.Annotations)
+}
+
+//breakingChanges returns why the Instance i of old can't use ov instead: parameters it sets that ov
+// doesn't define, required parameters it doesn't set, templates that don't render with its parameters,
+// and plans that run while their definition changes
+func breakingChanges(old, ov *v1beta1.OperatorVersion, i *v1beta1.Instance) []string {
+	problems := []string{}
+
+	params := map[string]string{}
+	defined := map[string]bool{}
+	for _, p := range ov.Spec.Parameters {
+		defined[p.Name] = true
+		if p.Default != nil {
+			params[p.Name] = *p.Default
+		} else if p.Required != nil && *p.Required {
+			if _, ok := i.Spec.Parameters[p.Name]; !ok {
+				problems = append(problems, fmt.Sprintf("parameter %s is required and has no default, but isn't set", p.Name))
+			}
+		}
+	}
+	for _, k := range sortedKeys(i.Spec.Parameters) {
+		if !defined[k] {
+			problems = append(problems, fmt.Sprintf("parameter %s is set, but no longer defined", k))
+		}
+		params[k] = i.Spec.Parameters[k]
+	}
+
+	vals := renderer.NewVariableMap().
+		WithDefaults().
+		WithInstance(ov.Spec.Operator.Name, i.Name, i.Namespace, ov.Spec.AppVersion, ov.Spec.Version).
+		WithParameterStrings(params).
+		WithPipes(pipeKeys(ov))
+	// new parameter values can break any template, otherwise only changed templates are rendered
+	paramsChanged := !equality.Semantic.DeepEqual(old.Spec.Parameters, ov.Spec.Parameters)
+	engine := renderer.New()
+	for _, name := range sortedKeys(ov.Spec.Templates) {
+		if !paramsChanged && old.Spec.Templates[name] == ov.Spec.Templates[name] {
+			continue
+		}
+		if _, err := engine.Render(name, ov.Spec.Templates[name], vals); err != nil {
+			problems = append(problems, fmt.Sprintf("template %s: %v", name, err))
+		}
+	}
+
+	if plan := i.GetPlanInProgress(); plan != nil {
+		if !equality.Semantic.DeepEqual(old.Spec.Plans, ov.Spec.Plans) ||
+			!equality.Semantic.DeepEqual(old.Spec.Tasks, ov.Spec.Tasks) ||
+			!equality.Semantic.DeepEqual(old.Spec.Templates, ov.Spec.Templates) {
+			problems = append(problems, fmt.Sprintf("plan %s is %s, and the plans, tasks or templates changed", plan.Name, plan.Status))
+		}
+	}
+	return problems
+}
+
+//pipeKeys returns placeholder values for the pipe files of ov, which only exist while a plan runs
+func pipeKeys(ov *v1beta1.OperatorVersion) map[string]string {
+	pipes := map[string]string{}
+	for _, t := range ov.Spec.Tasks {
+		for _, p := range t.Spec.Pipe {
+			pipes[p.Key] = p.Key
+		}
+	}
+	return pipes
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/apis/kudo/v1beta1"
+	kudofake "github.com/kudobuilder/kudo/pkg/client/clientset/versioned/fake"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/packages"
+)
+
+func testInlinePackage(t *testing.T, configMap string) *packages.Package {
+	pkg, err := inlinePackage(testOperatorYAML, testParamsYAML, map[string]string{"configmap.yaml": configMap}, "inline", "")
+	assert.NoError(t, err)
+	return pkg
+}
+
+func updateActions(kudoClient *kudofake.Clientset) []string {
+	updates := []string{}
+	for _, a := range kudoClient.Actions() {
+		if a.GetVerb() == "update" || a.GetVerb() == "create" {
+			updates = append(updates, a.GetVerb()+" "+a.GetResource().Resource)
+		}
+	}
+	return updates
+}
+
+func TestApplyPackage(t *testing.T) {
+	kudoClient := kudofake.NewSimpleClientset()
+	assert.NoError(t, applyPackage(kudoClient, testInlinePackage(t, testConfigMap), "default"))
+	assert.Equal(t, []string{"create operators", "create operatorversions"}, updateActions(kudoClient))
+
+	// an unchanged package isn't applied again
+	kudoClient.ClearActions()
+	assert.NoError(t, applyPackage(kudoClient, testInlinePackage(t, testConfigMap), "default"))
+	assert.Empty(t, updateActions(kudoClient))
+
+	// changed templates are
+	changed := testConfigMap + "  farewell: bye\n"
+	assert.NoError(t, applyPackage(kudoClient, testInlinePackage(t, changed), "default"))
+	assert.Equal(t, []string{"update operatorversions"}, updateActions(kudoClient))
+	ov, err := kudoClient.KudoV1beta1().OperatorVersions("default").Get("inline-0.1.0", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, changed, ov.Spec.Templates["configmap.yaml"])
+
+	// so is new metadata, without dropping what's there
+	kudoClient.ClearActions()
+	pkg := testInlinePackage(t, changed)
+	addPackageMetadata(pkg, map[string]string{"team": "data"}, nil)
+	assert.NoError(t, applyPackage(kudoClient, pkg, "default"))
+	assert.Equal(t, []string{"update operators", "update operatorversions"}, updateActions(kudoClient))
+	ov, err = kudoClient.KudoV1beta1().OperatorVersions("default").Get("inline-0.1.0", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "data", ov.Labels["team"])
+}
+
+func TestApplyPackageInstances(t *testing.T) {
+	instance := &v1beta1.Instance{
+		ObjectMeta: metav1.ObjectMeta{Name: "inline", Namespace: "default"},
+		Spec: v1beta1.InstanceSpec{
+			OperatorVersion: corev1.ObjectReference{Name: "inline-0.1.0"},
+			Parameters:      map[string]string{"GREETING": "hi"},
+		},
+	}
+	kudoClient := kudofake.NewSimpleClientset(instance)
+	assert.NoError(t, applyPackage(kudoClient, testInlinePackage(t, testConfigMap), "default"))
+
+	// templates that render with the parameters of the Instance are fine
+	assert.NoError(t, applyPackage(kudoClient, testInlinePackage(t, testConfigMap+"  name: {{ .Name }}\n"), "default"))
+
+	// templates that don't render aren't applied
+	kudoClient.ClearActions()
+	err := applyPackage(kudoClient, testInlinePackage(t, testConfigMap+"{{ if ne .Params.GREETING \"hello\" }}{{ fail \"only hello is supported\" }}{{ end }}\n"), "default")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the changes to OperatorVersion inline-0.1.0 would break the Instances using it")
+	assert.Contains(t, err.Error(), "Instance default/inline: template configmap.yaml: error rendering template")
+	assert.Contains(t, err.Error(), "only hello is supported")
+	assert.Empty(t, updateActions(kudoClient))
+
+	// neither are parameters the Instance sets that are removed
+	pkg := testInlinePackage(t, testConfigMap)
+	pkg.Resources.OperatorVersion.Spec.Parameters = nil
+	err = applyPackage(kudoClient, pkg, "default")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Instance default/inline: parameter GREETING is set, but no longer defined")
+}
+
+func TestBreakingChanges(t *testing.T) {
+	required := true
+	old := testInlinePackage(t, testConfigMap).Resources.OperatorVersion
+	ov := testInlinePackage(t, testConfigMap).Resources.OperatorVersion
+	ov.Spec.Parameters = append(ov.Spec.Parameters, v1beta1.Parameter{Name: "SIZE", Required: &required})
+	instance := &v1beta1.Instance{
+		ObjectMeta: metav1.ObjectMeta{Name: "inline", Namespace: "default"},
+		Spec:       v1beta1.InstanceSpec{OperatorVersion: corev1.ObjectReference{Name: "inline-0.1.0"}},
+	}
+	assert.Equal(t, []string{"parameter SIZE is required and has no default, but isn't set"}, breakingChanges(old, ov, instance))
+
+	instance.Spec.Parameters = map[string]string{"SIZE": "3"}
+	assert.Empty(t, breakingChanges(old, ov, instance))
+
+	// plans that are running can't change underneath
+	ov.Spec.Templates["configmap.yaml"] = testConfigMap + "  size: {{ .Params.SIZE }}\n"
+	instance.Status.PlanStatus = map[string]v1beta1.PlanStatus{
+		"deploy": {Name: "deploy", Status: v1beta1.ExecutionInProgress},
+	}
+	assert.Equal(t, []string{"plan deploy is IN_PROGRESS, and the plans, tasks or templates changed"}, breakingChanges(old, ov, instance))
+}
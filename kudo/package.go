@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/cmd/verify"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/packages"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/packages/convert"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/packages/reader"
+)
+
+// the directory inline packages are written to, in memory
+const inlinePackagePath = "/package"
+
+//readLocalPackage reads the operator package in a directory or .tgz, and checks that it is
+// the operator, and version if one is given, that the resource asks for
+func readLocalPackage(fs afero.Fs, path, name, version string) (*packages.Package, error) {
+	pkg, err := reader.Read(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operator package %s: %v", path, err)
+	}
+	return pkg, checkPackage(pkg, "operator package "+path, name, version)
+}
+
+//checkPackage fails when pkg isn't the operator, and version if one is given, that the resource asks for
+func checkPackage(pkg *packages.Package, source, name, version string) error {
+	if pkg.Resources.Operator.Name != name {
+		return fmt.Errorf("%s is for operator %s, not %s", source, pkg.Resources.Operator.Name, name)
+	}
+	if version != "" && pkg.Resources.OperatorVersion.Spec.Version != version {
+		return fmt.Errorf("%s has version %s, not %s", source, pkg.Resources.OperatorVersion.Spec.Version, version)
+	}
+	return nil
+}
+
+//inlinePackage assembles an operator package from the contents of its files. The files are parsed
+// by the KUDO packages reader and verified like `kudo package verify` does.
+func inlinePackage(operatorYAML, paramsYAML string, templates map[string]string, name, version string) (*packages.Package, error) {
+	fs := afero.NewMemMapFs()
+	files := map[string]string{}
+	if operatorYAML != "" {
+		files[reader.OperatorFileName] = operatorYAML
+	}
+	if paramsYAML != "" {
+		files[reader.ParamsFileName] = paramsYAML
+	}
+	for k, v := range templates {
+		if k != filepath.Base(k) {
+			return nil, fmt.Errorf("template %s has to be a file name without a directory", k)
+		}
+		files[filepath.Join("templates", k)] = v
+	}
+	for k, v := range files {
+		if err := afero.WriteFile(fs, filepath.Join(inlinePackagePath, k), []byte(v), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	pf, err := reader.FromDir(fs, inlinePackagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inline operator package: %v", err)
+	}
+	if err := verifyPackageFiles(pf); err != nil {
+		return nil, err
+	}
+	resources, err := convert.FilesToResources(pf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inline operator package: %v", err)
+	}
+	pkg := &packages.Package{Resources: resources, Files: pf}
+	return pkg, checkPackage(pkg, "inline operator package", name, version)
+}
+
+//verifyPackageFiles runs the verifiers of `kudo package verify`. Warnings are only logged.
+func verifyPackageFiles(pf *packages.Files) error {
+	res := verify.PackageFiles(pf)
+	for _, w := range res.Warnings {
+		log.Printf("[WARN] [KUDO] Operator package %s: %s", pf.Operator.Name, w)
+	}
+	if res.IsValid() {
+		return nil
+	}
+	return fmt.Errorf("operator package %s is invalid:\n  %s", pf.Operator.Name, strings.Join(res.Errors, "\n  "))
+}
+
+//packageHash returns a hash of the contents of a package directory or .tgz. Files in a directory
+// are hashed in order with their relative paths and sizes, so that renames change the hash too.
+func packageHash(fs afero.Fs, path string) (string, error) {
+	fi, err := fs.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if !fi.IsDir() {
+		if err := hashFile(fs, path, h); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%x", h.Sum(nil)), nil
+	}
+
+	files := []string{}
+	sizes := map[string]int64{}
+	err = afero.Walk(fs, path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, p)
+			sizes[p] = info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		rel, err := filepath.Rel(path, f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), sizes[f])
+		if err := hashFile(fs, f, h); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func hashFile(fs afero.Fs, path string, w io.Writer) error {
+	f, err := fs.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
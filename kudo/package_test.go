@@ -0,0 +1,222 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+const examplePackage = "../examples/terraform/kafka"
+
+func TestReadLocalPackage(t *testing.T) {
+	fs := afero.NewOsFs()
+
+	pkg, err := readLocalPackage(fs, examplePackage, "kafka", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "kafka", pkg.Resources.Operator.Name)
+	assert.Equal(t, "1.2.0", pkg.Resources.OperatorVersion.Spec.Version)
+
+	_, err = readLocalPackage(fs, examplePackage, "kafka", "1.2.0")
+	assert.NoError(t, err)
+
+	_, err = readLocalPackage(fs, examplePackage, "zookeeper", "")
+	assert.EqualError(t, err, "operator package ../examples/terraform/kafka is for operator kafka, not zookeeper")
+
+	_, err = readLocalPackage(fs, examplePackage, "kafka", "1.3.1")
+	assert.EqualError(t, err, "operator package ../examples/terraform/kafka has version 1.2.0, not 1.3.1")
+
+	_, err = readLocalPackage(fs, "../examples/terraform/missing", "kafka", "")
+	assert.Error(t, err)
+}
+
+func TestPackageHash(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(fs, "/pkg/operator.yaml", []byte("name: test\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/pkg/params.yaml", []byte("parameters: []\n"), 0644))
+	assert.NoError(t, afero.WriteFile(fs, "/pkg/templates/deploy.yaml", []byte("kind: Deployment\n"), 0644))
+
+	hash, err := packageHash(fs, "/pkg")
+	assert.NoError(t, err)
+	again, err := packageHash(fs, "/pkg")
+	assert.NoError(t, err)
+	assert.Equal(t, hash, again)
+
+	// edited templates change the hash
+	assert.NoError(t, afero.WriteFile(fs, "/pkg/templates/deploy.yaml", []byte("kind: StatefulSet\n"), 0644))
+	edited, err := packageHash(fs, "/pkg")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, edited)
+
+	// so do renames
+	assert.NoError(t, fs.Rename("/pkg/templates/deploy.yaml", "/pkg/templates/statefulset.yaml"))
+	renamed, err := packageHash(fs, "/pkg")
+	assert.NoError(t, err)
+	assert.NotEqual(t, edited, renamed)
+
+	// a tarball is hashed as a whole
+	assert.NoError(t, afero.WriteFile(fs, "/kafka-1.2.0.tgz", []byte("not really gzipped"), 0644))
+	tgz, err := packageHash(fs, "/kafka-1.2.0.tgz")
+	assert.NoError(t, err)
+	assert.Len(t, tgz, 64)
+
+	_, err = packageHash(fs, "/missing")
+	assert.Error(t, err)
+}
+
+func TestOperatorPackageHashDiff(t *testing.T) {
+	config := NewConfig(nil)
+	hash, err := packageHash(afero.NewOsFs(), examplePackage)
+	assert.NoError(t, err)
+
+	state := &terraform.InstanceState{
+		ID: id("kafka-1.2.0", "default"),
+		Attributes: map[string]string{
+			"operator_name":      "kafka",
+			"operator_namespace": "default",
+			"operator_version":   "1.2.0",
+			"object_name":        "kafka-1.2.0",
+			"package_path":       examplePackage,
+			"package_hash":       "stale",
+			"labels_all.%":       "0",
+			"annotations_all.%":  "0",
+		},
+	}
+	cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"operator_name": "kafka",
+		"package_path":  examplePackage,
+	})
+	diff, err := resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, diff.Attributes["package_hash"].New)
+
+	state.Attributes["package_hash"] = hash
+	diff, err = resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Nil(t, diff)
+}
+
+func TestOperatorLocalPackageDiff(t *testing.T) {
+	config := NewConfig(nil)
+	hash, err := packageHash(afero.NewOsFs(), examplePackage)
+	assert.NoError(t, err)
+
+	// a new resource shows the OperatorVersion of the package
+	cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"operator_name": "kafka",
+		"package_path":  examplePackage,
+	})
+	diff, err := resourceOperator().Diff(nil, cfg, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", diff.Attributes["operator_version"].New)
+	assert.Equal(t, "kafka-1.2.0", diff.Attributes["object_name"].New)
+
+	// a version computed from an earlier package follows the package
+	state := &terraform.InstanceState{
+		ID: id("kafka-1.1.0", "default"),
+		Attributes: map[string]string{
+			"operator_name":      "kafka",
+			"operator_namespace": "default",
+			"operator_version":   "1.1.0",
+			"object_name":        "kafka-1.1.0",
+			"package_path":       examplePackage,
+			"package_hash":       hash,
+			"labels_all.%":       "0",
+			"annotations_all.%":  "0",
+		},
+	}
+	diff, err = resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", diff.Attributes["operator_version"].New)
+	assert.Equal(t, "kafka-1.2.0", diff.Attributes["object_name"].New)
+
+	// a version set in the configuration has to match the package
+	cfg = terraform.NewResourceConfigRaw(map[string]interface{}{
+		"operator_name":    "kafka",
+		"operator_version": "1.3.0",
+		"package_path":     examplePackage,
+	})
+	_, err = resourceOperator().Diff(state, cfg, config)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "operator package ../examples/terraform/kafka has version 1.2.0, not 1.3.0")
+}
+
+const testOperatorYAML = `apiVersion: kudo.dev/v1beta1
+name: "inline"
+operatorVersion: "0.1.0"
+kubernetesVersion: 1.15.0
+tasks:
+  - name: app
+    kind: Apply
+    spec:
+      resources:
+        - configmap.yaml
+plans:
+  deploy:
+    strategy: serial
+    phases:
+      - name: main
+        strategy: parallel
+        steps:
+          - name: everything
+            tasks:
+              - app
+`
+
+const testParamsYAML = `apiVersion: kudo.dev/v1beta1
+parameters:
+  - name: GREETING
+    default: hello
+`
+
+const testConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}
+data:
+  greeting: {{ .Params.GREETING }}
+`
+
+func TestInlinePackage(t *testing.T) {
+	templates := map[string]string{"configmap.yaml": testConfigMap}
+
+	pkg, err := inlinePackage(testOperatorYAML, testParamsYAML, templates, "inline", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "inline-0.1.0", pkg.Resources.OperatorVersion.Name)
+	assert.Equal(t, testConfigMap, pkg.Resources.OperatorVersion.Spec.Templates["configmap.yaml"])
+	assert.Equal(t, "hello", *pkg.Resources.OperatorVersion.Spec.Parameters[0].Default)
+
+	_, err = inlinePackage(testOperatorYAML, testParamsYAML, templates, "inline", "0.2.0")
+	assert.EqualError(t, err, "inline operator package has version 0.1.0, not 0.2.0")
+
+	_, err = inlinePackage(testOperatorYAML, "", templates, "inline", "")
+	assert.EqualError(t, err, "failed to read inline operator package: operator package missing params.yaml in /package")
+
+	_, err = inlinePackage(testOperatorYAML, testParamsYAML, map[string]string{"nested/configmap.yaml": testConfigMap}, "inline", "")
+	assert.EqualError(t, err, "template nested/configmap.yaml has to be a file name without a directory")
+
+	// verified like kudo package verify does
+	_, err = inlinePackage(testOperatorYAML, testParamsYAML, map[string]string{"configmap.yaml": testConfigMap + "  other: {{ .Params.UNDEFINED }}\n"}, "inline", "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "operator package inline is invalid:")
+	assert.Contains(t, err.Error(), "UNDEFINED")
+}
+
+func TestOperatorInlinePackageDiff(t *testing.T) {
+	config := NewConfig(nil)
+	raw := map[string]interface{}{
+		"operator_name": "inline",
+		"operator_yaml": testOperatorYAML,
+		"params_yaml":   testParamsYAML,
+		"templates":     map[string]interface{}{"configmap.yaml": testConfigMap},
+	}
+	diff, err := resourceOperator().Diff(nil, terraform.NewResourceConfigRaw(raw), config)
+	assert.NoError(t, err)
+	assert.Equal(t, "0.1.0", diff.Attributes["operator_version"].New)
+	assert.Equal(t, "inline-0.1.0", diff.Attributes["object_name"].New)
+
+	raw["templates"] = map[string]interface{}{"configmap.yaml": testConfigMap + "  other: {{ .Params.UNDEFINED }}\n"}
+	_, err = resourceOperator().Diff(nil, terraform.NewResourceConfigRaw(raw), config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const kudoGroup = "kudo.dev"
+
+//permission is a verb on a resource that an operation of the provider needs
+type permission struct {
+	verb      string
+	group     string
+	resource  string
+	namespace string
+}
+
+func (p permission) String() string {
+	resource := p.resource
+	if p.group != "" {
+		resource = p.resource + "." + p.group
+	}
+	if p.namespace == "" {
+		return fmt.Sprintf("%s %s (cluster-wide)", p.verb, resource)
+	}
+	return fmt.Sprintf("%s %s in namespace %s", p.verb, resource, p.namespace)
+}
+
+//permissions returns a permission for each combination of verb and resource
+func permissions(namespace, group string, resources []string, verbs ...string) []permission {
+	perms := []permission{}
+	for _, r := range resources {
+		for _, v := range verbs {
+			perms = append(perms, permission{verb: v, group: group, resource: r, namespace: namespace})
+		}
+	}
+	return perms
+}
+
+//operatorPermissions are needed to install or update an Operator and OperatorVersion from a package,
+// patch their metadata, and check the Instances using the OperatorVersion
+func operatorPermissions(namespace string) []permission {
+	perms := permissions(namespace, kudoGroup, []string{"operators", "operatorversions"}, "get", "create", "update", "patch")
+	return append(perms, permissions(namespace, kudoGroup, []string{"instances"}, "list")...)
+}
+
+//operatorDeletePermissions are needed to remove an OperatorVersion
+func operatorDeletePermissions(namespace string) []permission {
+	return permissions(namespace, kudoGroup, []string{"operatorversions"}, "delete")
+}
+
+//instanceReadPermissions are needed to read an Instance and the objects it created
+func instanceReadPermissions(namespace, ovNamespace string) []permission {
+	perms := permissions(namespace, kudoGroup, []string{"instances"}, "get")
+	perms = append(perms, permissions(ovNamespace, kudoGroup, []string{"operatorversions"}, "get")...)
+	perms = append(perms, permissions(namespace, "", []string{"pods", "services", "configmaps", "persistentvolumeclaims"}, "list")...)
+	perms = append(perms, permissions(namespace, "apps", []string{"deployments", "statefulsets"}, "list")...)
+	return perms
+}
+
+//instanceCreatePermissions are needed to create an Instance and wait for its plan
+func instanceCreatePermissions(namespace, ovNamespace string) []permission {
+	perms := permissions(namespace, kudoGroup, []string{"instances"}, "create")
+	return append(perms, instanceReadPermissions(namespace, ovNamespace)...)
+}
+
+//instanceUpdatePermissions are needed to patch an Instance and wait for its plan
+func instanceUpdatePermissions(namespace, ovNamespace string) []permission {
+	perms := permissions(namespace, kudoGroup, []string{"instances"}, "patch")
+	return append(perms, instanceReadPermissions(namespace, ovNamespace)...)
+}
+
+//instanceDeletePermissions are needed to delete an Instance, and its PVCs with cleanupPVCs
+func instanceDeletePermissions(namespace string, cleanupPVCs bool) []permission {
+	perms := permissions(namespace, kudoGroup, []string{"instances"}, "delete", "get")
+	if cleanupPVCs {
+		perms = append(perms, permissions(namespace, "", []string{"persistentvolumeclaims"}, "delete", "get")...)
+	}
+	return perms
+}
+
+//installationPermissions are needed to install KUDO, or to update it in place. Installing with
+// cert-manager also needs the Certificate API, in certManagerGroup.
+func installationPermissions(o InstallOptions, certManagerGroup string) []permission {
+	perms := permissions("", "apiextensions.k8s.io", []string{"customresourcedefinitions"}, "get", "list", "watch", "create", "update")
+	if o.CRDsOnly {
+		return perms
+	}
+	perms = append(perms, permissions("", "", []string{"namespaces"}, "get", "create")...)
+	perms = append(perms, permissions("", "rbac.authorization.k8s.io", []string{"clusterrolebindings"}, "get", "create")...)
+	perms = append(perms, permissions("", "admissionregistration.k8s.io", []string{"mutatingwebhookconfigurations"}, "get", "create", "update")...)
+	perms = append(perms, permissions(o.Namespace, "", []string{"serviceaccounts"}, "get", "create")...)
+	perms = append(perms, permissions(o.Namespace, "", []string{"services", "secrets"}, "get", "create", "update")...)
+	perms = append(perms, permissions(o.Namespace, "", []string{"endpoints"}, "list", "watch")...)
+	perms = append(perms, permissions(o.Namespace, "apps", []string{"statefulsets"}, "get", "list", "watch", "create", "update")...)
+	perms = append(perms, permissions(o.Namespace, "policy", []string{"poddisruptionbudgets"}, "get", "create", "update", "delete")...)
+	if o.WebhookTLS.Mode == webhookTLSCertManager {
+		perms = append(perms, permissions(o.Namespace, certManagerGroup, []string{"certificates"}, "get", "create", "update")...)
+	}
+	if o.highlyAvailable() {
+		perms = append(perms, permissions(o.Namespace, "", []string{"configmaps"}, "list", "watch")...)
+		perms = append(perms, permissions(o.Namespace, "", []string{"pods"}, "get")...)
+	}
+	return perms
+}
+
+//installationUpgradePermissions are needed to upgrade KUDO, which checks every Instance first
+func installationUpgradePermissions(o InstallOptions, certManagerGroup string) []permission {
+	perms := permissions("", kudoGroup, []string{"instances"}, "list")
+	perms = append(perms, permissions("", kudoGroup, []string{"operators", "operatorversions"}, "get")...)
+	return append(perms, installationPermissions(o, certManagerGroup)...)
+}
+
+//installationDeletePermissions are needed to remove KUDO
+func installationDeletePermissions(o InstallOptions, u UninstallOptions, certManagerGroup string) []permission {
+	perms := []permission{}
+	if !u.Force {
+		perms = append(perms, permissions("", kudoGroup, []string{"instances", "operatorversions"}, "list")...)
+	}
+	if u.DeleteCRDs {
+		perms = append(perms, permissions("", "apiextensions.k8s.io", []string{"customresourcedefinitions"}, "get", "delete")...)
+		perms = append(perms, permissions("", kudoGroup, []string{"instances"}, "list")...)
+	}
+	if o.CRDsOnly {
+		return perms
+	}
+	perms = append(perms, permissions("", "admissionregistration.k8s.io", []string{"mutatingwebhookconfigurations"}, "delete")...)
+	perms = append(perms, permissions(o.Namespace, "", []string{"services", "secrets"}, "delete")...)
+	perms = append(perms, permissions(o.Namespace, "apps", []string{"statefulsets"}, "delete")...)
+	perms = append(perms, permissions(o.Namespace, "policy", []string{"poddisruptionbudgets"}, "delete")...)
+	if certManagerGroup != "" {
+		perms = append(perms, permissions(o.Namespace, certManagerGroup, []string{"certificates"}, "delete")...)
+	}
+	opts := o.ToKUDOOpts()
+	if opts.IsDefaultServiceAccount() {
+		perms = append(perms, permissions("", "rbac.authorization.k8s.io", []string{"clusterrolebindings"}, "delete")...)
+		perms = append(perms, permissions(o.Namespace, "", []string{"serviceaccounts"}, "delete")...)
+	}
+	if opts.IsDefaultNamespace() {
+		perms = append(perms, permissions("", "", []string{"namespaces"}, "delete")...)
+	}
+	return perms
+}
+
+//checkPermissions asks the API server whether the credentials are allowed everything in perms,
+// and reports all permissions that are missing together
+func checkPermissions(client kubernetes.Interface, perms []permission) error {
+	missing := []string{}
+	seen := map[permission]bool{}
+	for _, p := range perms {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:      p.verb,
+					Group:     p.group,
+					Resource:  p.resource,
+					Namespace: p.namespace,
+				},
+			},
+		}
+		result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(review)
+		if err != nil {
+			return fmt.Errorf("error checking permission to %s: %v", p, err)
+		}
+		if !result.Status.Allowed {
+			missing = append(missing, p.String())
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("the credentials are missing permissions:\n  %s", strings.Join(missing, "\n  "))
+	}
+	return nil
+}
+
+//preflight checks perms before an operation changes anything, unless disabled on the provider
+func (c Config) preflight(perms []permission) error {
+	if !c.RBACPreflight {
+		return nil
+	}
+	client, err := c.GetKubernetesClient()
+	if err != nil {
+		return err
+	}
+	log.Printf("[DEBUG] Checking %d permissions", len(perms))
+	return checkPermissions(client, perms)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	reviews := 0
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		// only the KUDO types can be read and created
+		review.Status.Allowed = attrs.Group == kudoGroup && attrs.Verb != "delete"
+		return true, review, nil
+	})
+
+	assert.NoError(t, checkPermissions(client, operatorPermissions("default")))
+
+	err := checkPermissions(client, instanceCreatePermissions("default", "kudo"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "list pods in namespace default")
+	assert.Contains(t, err.Error(), "list statefulsets.apps in namespace default")
+	assert.NotContains(t, err.Error(), "instances")
+
+	err = checkPermissions(client, instanceDeletePermissions("default", true))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "delete instances.kudo.dev in namespace default")
+	assert.Contains(t, err.Error(), "delete persistentvolumeclaims in namespace default")
+
+	// every permission is only asked for once
+	reviews = 0
+	perms := append(operatorPermissions("default"), operatorPermissions("default")...)
+	assert.NoError(t, checkPermissions(client, perms))
+	assert.Equal(t, 9, reviews)
+}
+
+func TestOperatorPermissions(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		// enough to create Operators, but not to update them
+		review.Status.Allowed = review.Spec.ResourceAttributes.Verb == "get" || review.Spec.ResourceAttributes.Verb == "create"
+		return true, review, nil
+	})
+
+	err := checkPermissions(client, operatorPermissions("default"))
+	assert.EqualError(t, err, `the credentials are missing permissions:
+  list instances.kudo.dev in namespace default
+  patch operators.kudo.dev in namespace default
+  patch operatorversions.kudo.dev in namespace default
+  update operators.kudo.dev in namespace default
+  update operatorversions.kudo.dev in namespace default`)
+}
+
+func TestInstallationPermissions(t *testing.T) {
+	opts := InstallOptions{Namespace: "kudo-system", ServiceAccount: "kudo-manager", WebhookTLS: WebhookTLSOptions{Mode: webhookTLSCertManager}}
+	perms := []string{}
+	for _, p := range installationPermissions(opts, "cert-manager.io") {
+		perms = append(perms, p.String())
+	}
+	assert.Contains(t, perms, "create customresourcedefinitions.apiextensions.k8s.io (cluster-wide)")
+	assert.Contains(t, perms, "create clusterrolebindings.rbac.authorization.k8s.io (cluster-wide)")
+	assert.Contains(t, perms, "create mutatingwebhookconfigurations.admissionregistration.k8s.io (cluster-wide)")
+	assert.Contains(t, perms, "create namespaces (cluster-wide)")
+	assert.Contains(t, perms, "update statefulsets.apps in namespace kudo-system")
+	assert.Contains(t, perms, "create poddisruptionbudgets.policy in namespace kudo-system")
+	assert.Contains(t, perms, "create certificates.cert-manager.io in namespace kudo-system")
+
+	// only the CRDs
+	opts.CRDsOnly = true
+	assert.Len(t, installationPermissions(opts, "cert-manager.io"), 5)
+
+	// the default namespace is removed with KUDO
+	opts = InstallOptions{Namespace: "kudo-system", ServiceAccount: "kudo-manager"}
+	perms = []string{}
+	for _, p := range installationDeletePermissions(opts, UninstallOptions{DeleteCRDs: true}, "") {
+		perms = append(perms, p.String())
+	}
+	assert.Contains(t, perms, "list instances.kudo.dev (cluster-wide)")
+	assert.Contains(t, perms, "delete customresourcedefinitions.apiextensions.k8s.io (cluster-wide)")
+	assert.Contains(t, perms, "delete mutatingwebhookconfigurations.admissionregistration.k8s.io (cluster-wide)")
+	assert.Contains(t, perms, "delete namespaces (cluster-wide)")
+	assert.NotContains(t, perms, "delete certificates.cert-manager.io in namespace kudo-system")
+}
@@ -5,27 +5,24 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/logging"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+	"github.com/spf13/afero"
 
-	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/dynamic"
-	kubernetes "k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
-
-	"github.com/kudobuilder/kudo/pkg/client/clientset/versioned"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/setup"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/util/kudo"
-	"github.com/kudobuilder/kudo/pkg/version"
+	"k8s.io/client-go/transport"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -45,9 +42,14 @@ provider "kubernetes" {
 //Provider implements the *schema.Provider interface
 func Provider() *schema.Provider {
 	p := &schema.Provider{
+		DataSourcesMap: map[string]*schema.Resource{
+			"kudo_installation_manifests": dataSourceInstallationManifests(),
+		},
 		ResourcesMap: map[string]*schema.Resource{
-			"kudo_operator": resourceOperator(),
-			"kudo_instance": resourceInstance(),
+			"kudo_operator":     withThrottlingSummary(resourceOperator()),
+			"kudo_instance":     withThrottlingSummary(resourceInstance()),
+			"kudo_installation": withThrottlingSummary(resourceInstallation()),
+			"kudo_repository":   withThrottlingSummary(resourceRepository()),
 		},
 		Schema: map[string]*schema.Schema{
 			// Most of these taken to match
@@ -60,8 +62,21 @@ func Provider() *schema.Provider {
 						"KUBE_CONFIG",
 						"KUBECONFIG",
 					},
-					"~/.kube/config"),
-				Description: "Path to the kube config file, defaults to ~/.kube/config",
+					nil),
+				Description: "Path to the kube config file, defaults to " + defaultConfigPath + " when neither config_paths nor config_raw is set. A list of paths separated like KUBECONFIG is merged the same way.",
+			},
+			"config_paths": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "List of kube config files, merged with the same precedence rules as KUBECONFIG. Takes precedence over config_path.",
+			},
+			"config_raw": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Sensitive:   true,
+				DefaultFunc: schema.EnvDefaultFunc("KUBE_CONFIG_RAW", nil),
+				Description: "Content of a kube config file, e.g. the output of the resource that created the cluster. Takes precedence over config_paths and config_path.",
 			},
 			//Other kube config possibilities
 			"host": &schema.Schema{
@@ -109,6 +124,38 @@ func Provider() *schema.Provider {
 			},
 			//
 
+			"proxy_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUBE_PROXY_URL", ""),
+				Description: "URL of the HTTP proxy used to reach the Kubernetes control plane endpoint.",
+			},
+			"tls_server_name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUBE_TLS_SERVER_NAME", ""),
+				Description: "Server name used to verify the certificate of the Kubernetes control plane endpoint, if it differs from the host.",
+			},
+			"request_timeout": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUBE_REQUEST_TIMEOUT", ""),
+				Description: "Timeout for a single request to the Kubernetes API, e.g. 30s. Defaults to no timeout.",
+			},
+			"client_qps": {
+				Type:        schema.TypeFloat,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUBE_CLIENT_QPS", 5),
+				Description: "Maximum queries per second to the Kubernetes API, shared by all clients of the provider.",
+			},
+			"client_burst": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUBE_CLIENT_BURST", 10),
+				Description: "Maximum burst of queries to the Kubernetes API above client_qps.",
+			},
+			//
+
 			"config_context": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -132,53 +179,187 @@ func Provider() *schema.Provider {
 				DefaultFunc: schema.EnvDefaultFunc("KUBE_TOKEN", ""),
 				Description: "Token to authenticate an service account",
 			},
+			"exec": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"api_version": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "API version of the ExecCredential the plugin returns, e.g. client.authentication.k8s.io/v1alpha1",
+						},
+						"command": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Command to execute to obtain credentials",
+						},
+						"args": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"env": {
+							Type:     schema.TypeMap,
+							Optional: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+				Description: "Exec-based credential plugin, e.g. aws eks get-token. Credentials are refreshed when they expire.",
+			},
+			"impersonate": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"user": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Username to impersonate",
+						},
+						"groups": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Groups to impersonate",
+						},
+						"extra": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"key": {
+										Type:     schema.TypeString,
+										Required: true,
+									},
+									"values": {
+										Type:     schema.TypeList,
+										Required: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+									},
+								},
+							},
+							Description: "Extra user information to impersonate, e.g. scopes",
+						},
+					},
+				},
+				Description: "Act as another user for every request made by the provider",
+			},
 			"load_config_file": {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				DefaultFunc: schema.EnvDefaultFunc("KUBE_LOAD_CONFIG_FILE", true),
-				Description: "Load local kubeconfig.",
+				Description: "Load local kubeconfig. Set to false when the cluster is created in the same configuration, so an unknown host is not resolved from the local kubeconfig.",
 			},
 			//KUDO specific configs
+			// Deprecated in favour of the kudo_installation resource. They are only
+			// used when kudo_version is set on the provider.
 			"image": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "kudobuilder/controller",
 				Description: "Override KUDO controller base image",
+				Deprecated:  legacyInstallDeprecation,
 			},
 			"service_account": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "kudo-manager",
 				Description: "Override the default serviceAccount kudo-manager",
+				Deprecated:  legacyInstallDeprecation,
+			},
+			"crds_only": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Only install the KUDO CRDs, without the controller",
+				Deprecated:  legacyInstallDeprecation,
 			},
 			"wait": &schema.Schema{
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     false,
 				Description: "Block until KUDO manager is running and ready to recieve requests",
+				Deprecated:  legacyInstallDeprecation,
 			},
 			"wait_timeout": &schema.Schema{
 				Type:        schema.TypeInt,
 				Optional:    true,
 				Description: "Wait timeout to be used.",
 				Default:     300,
+				Deprecated:  legacyInstallDeprecation,
 			},
 			"kudo_version": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "KUDO version to install",
-				Default:     "0.14.0",
+				Description: "KUDO version to install before the first kudo_operator or kudo_instance is created or updated. It has no default any more: without it or a kudo_installation resource, they fail when KUDO isn't installed. Use the kudo_installation resource instead",
+				Deprecated:  legacyInstallDeprecation,
 			},
 			"namespace": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "kudo-system",
-				Description: "Namespace to install KUDO into",
+				Description: "Namespace KUDO is installed in, and installed into when kudo_version is set",
+			},
+			"version_check": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("KUDO_VERSION_CHECK", versionCheckWarn),
+				ValidateFunc: validation.StringInSlice([]string{versionCheckWarn, versionCheckFail, versionCheckOff}, false),
+				Description:  "What to do when the installed KUDO controller or CRDs are not supported by the provider: warn, fail or off. fail also stops kudo_installation from upgrading an unsupported KUDO.",
+			},
+			"default_namespace": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "default",
+				Description: "Namespace of kudo_operator and kudo_instance resources that don't set one",
+			},
+			"default_labels": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Labels of all Instances, Operators and OperatorVersions, a resource's own labels take precedence",
+			},
+			"default_annotations": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Annotations of all Instances, Operators and OperatorVersions, a resource's own annotations take precedence",
+			},
+			"manage_kudo": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUDO_MANAGE", true),
+				Description: "Set to false when KUDO is installed by someone else. The provider then never installs or changes KUDO, and only verifies that it can be used in tenant_namespaces",
+			},
+			"tenant_namespaces": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Namespaces Instances are created in, checked when manage_kudo is false. Defaults to default_namespace",
+			},
+			"repository_file": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUDO_REPOSITORY_FILE", nil),
+				Description: "File the kudo_repository resources are kept in, like the repositories.yaml of the KUDO home. Defaults to kudo/repositories.yaml in the Terraform data directory.",
+			},
+			"rbac_preflight": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("KUDO_RBAC_PREFLIGHT", true),
+				Description: "Check with SelfSubjectAccessReviews that the credentials have all permissions an operation needs before changing anything",
 			},
 		},
 		// ConfigureFunc: kudoConfigureFunc,
 	}
 
+	unknown := &unknownSettings{}
+	unknown.track(p.Schema, connectionSettings)
+
 	p.ConfigureFunc = func(d *schema.ResourceData) (interface{}, error) {
 		terraformVersion := p.TerraformVersion
 		if terraformVersion == "" {
@@ -186,61 +367,142 @@ func Provider() *schema.Provider {
 			// We can therefore assume that if it's missing it's 0.10 or 0.11
 			terraformVersion = "0.11+compatible"
 		}
-		return kudoConfigureFunc(d, terraformVersion)
+		return kudoConfigureFunc(d, terraformVersion, unknown.take())
 	}
 
 	return p
 
 }
 
-//Config captures the configuration of the KUDO provider
-type Config struct {
-	KudoImage      string
-	Version        string
-	Wait           bool
-	WaitTimeout    int
-	ServiceAccount string
-	CRDsOnly       bool
-	Namespace      string
-
-	KubernetesClient *kubernetes.Clientset
-	KubernetesConfig *restclient.Config
-
-	KudoClient     *kudo.Client
-	RawKudoClient  *versioned.Clientset
-	KudoKubeClient *kube.Client
+// the kube config file that is loaded when no other is configured
+const defaultConfigPath = "~/.kube/config"
+
+//connectionSettings are the attributes that select the cluster and the credentials for it
+var connectionSettings = []string{
+	"host", "token", "username", "password", "client_certificate", "client_key", "cluster_ca_certificate",
+	"config_raw", "config_path", "config_paths", "config_context", "config_context_auth_info", "config_context_cluster",
+	"exec",
 }
 
-func (c Config) GetKubernetesClient() *kubernetes.Clientset {
-	return c.KubernetesClient
+//unknownValue is the value the SDK passes to the schema functions for a value that is only known during apply
+const unknownValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+//unknownSettings collects the connection settings that aren't known yet, e.g. because the cluster is
+// created in the same plan. The SDK doesn't tell the ConfigureFunc which values are unknown, it reads
+// them as unset, so they are collected while the configuration is diffed before it is called.
+type unknownSettings struct {
+	lock sync.Mutex
+	keys []string
 }
 
-//GetKudoClient returns a KUDO client from the configuration object
-func (c Config) GetKudoClient() *kudo.Client {
-	return c.KudoClient
+//track collects the unknown values of the given attributes, and of the attributes of their blocks
+func (u *unknownSettings) track(s map[string]*schema.Schema, keys []string) {
+	for _, k := range keys {
+		s[k].DiffSuppressFunc = u.record
+		if r, ok := s[k].Elem.(*schema.Resource); ok {
+			for _, sub := range r.Schema {
+				sub.DiffSuppressFunc = u.record
+			}
+		}
+	}
 }
 
-//GetKubernetesClient returns a Kubernetes client from the configuration object
-func (c Config) GetKudoKubernetesClient() *kube.Client {
-	return c.KudoKubeClient
+//record is the DiffSuppressFunc of the tracked attributes, it never suppresses a diff. Lists and maps
+// whose elements are unknown have an unknown count instead.
+func (u *unknownSettings) record(k, old, new string, d *schema.ResourceData) bool {
+	count := strings.HasSuffix(k, ".#") || strings.HasSuffix(k, ".%")
+	if new == unknownValue || count && new == "" {
+		u.lock.Lock()
+		defer u.lock.Unlock()
+		for _, known := range u.keys {
+			if known == k {
+				return false
+			}
+		}
+		u.keys = append(u.keys, k)
+	}
+	return false
 }
 
-//ToKUDOOpts returns a KUDO Options object for installing KUDO
-func (c Config) ToKUDOOpts() kudoinit.Options {
-	opts := kudoinit.Options{
-		Version:                       c.Version,
-		Namespace:                     c.Namespace,
-		TerminationGracePeriodSeconds: 300,
-		Image:                         fmt.Sprintf("%v:v%v", c.KudoImage, c.Version),
-		ServiceAccount:                c.ServiceAccount,
-		ImagePullPolicy:               "Always",
-		//TODO(@runyontr) use cert manager at some point
-		SelfSignedWebhookCA: true,
-	}
-	return opts
+//take returns the unknown settings collected since the last call
+func (u *unknownSettings) take() []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	keys := u.keys
+	u.keys = nil
+	sort.Strings(keys)
+	return keys
+}
+
+const legacyInstallDeprecation = "Installing KUDO from the provider block is deprecated, use the kudo_installation resource instead"
+
+func kudoConfigureFunc(data *schema.ResourceData, terraformVersion string, unknown []string) (interface{}, error) {
+	log.Println("[DEBUG] kudo provider configure:")
+	// The cluster may not exist yet when planning, so the connection settings are
+	// only resolved once a resource needs a client.
+	limiter := newMeasuredRateLimiter(float32(data.Get("client_qps").(float64)), data.Get("client_burst").(int))
+	c := NewConfig(func() (*restclient.Config, error) {
+		return restConfig(data, terraformVersion, unknown, limiter)
+	})
+	c.rateLimiter = limiter
+
+	c.Namespace = data.Get("namespace").(string)
+	c.VersionCheck = data.Get("version_check").(string)
+	c.RBACPreflight = data.Get("rbac_preflight").(bool)
+	c.DefaultNamespace = data.Get("default_namespace").(string)
+	c.DefaultLabels = expandStringMap(data.Get("default_labels").(map[string]interface{}))
+	c.DefaultAnnotations = expandStringMap(data.Get("default_annotations").(map[string]interface{}))
+	c.ManageKUDO = data.Get("manage_kudo").(bool)
+	c.TenantNamespaces = expandStringSlice(data.Get("tenant_namespaces").([]interface{}))
+	if v, ok := data.GetOk("repository_file"); ok {
+		c.repositories = newRepositoryStore(afero.NewOsFs(), v.(string))
+	}
+	if len(c.TenantNamespaces) == 0 {
+		c.TenantNamespaces = []string{c.DefaultNamespace}
+	}
+
+	//KUDO installation configurations, only honoured when kudo_version is set
+	if v, ok := data.GetOk("kudo_version"); ok {
+		if !c.ManageKUDO {
+			return nil, fmt.Errorf("kudo_version can't be set with manage_kudo = false")
+		}
+		opts := &InstallOptions{
+			Version: v.(string),
+		}
+		if v, ok := data.GetOk("image"); ok {
+			opts.KudoImage = v.(string)
+		}
+		if v, ok := data.GetOk("wait"); ok {
+			opts.Wait = v.(bool)
+		}
+		if v, ok := data.GetOk("wait_timeout"); ok {
+			opts.WaitTimeout = v.(int)
+		}
+		if v, ok := data.GetOk("service_account"); ok {
+			opts.ServiceAccount = v.(string)
+		}
+		if v, ok := data.GetOk("namespace"); ok {
+			opts.Namespace = v.(string)
+		}
+		if v, ok := data.GetOk("crds_only"); ok {
+			opts.CRDsOnly = v.(bool)
+		}
+		c.LegacyInstall = opts
+	}
+
+	log.Printf("[DEBUG] Config %+v", c)
+	return c, nil
 }
 
-func kudoConfigureFunc(data *schema.ResourceData, terraformVersion string) (interface{}, error) {
+//restConfig builds the client configuration from the provider settings. While some of the connection
+// settings are unknown, the configuration has no host, so the cluster is treated as unknown.
+func restConfig(data *schema.ResourceData, terraformVersion string, unknown []string, limiter *measuredRateLimiter) (*restclient.Config, error) {
+	if len(unknown) > 0 {
+		// the kube config file or the in-cluster config would connect to another cluster
+		log.Printf("[DEBUG] Not loading a kube config, %s not known yet", strings.Join(unknown, ", "))
+		return &restclient.Config{}, nil
+	}
+
 	// Mostly copied from kubernetes provider:
 	var cfg *restclient.Config
 	var err error
@@ -292,131 +554,116 @@ func kudoConfigureFunc(data *schema.ResourceData, terraformVersion string) (inte
 	if v, ok := data.GetOk("token"); ok {
 		cfg.BearerToken = v.(string)
 	}
-
-	if logging.IsDebugOrHigher() {
-		log.Printf("[DEBUG] Enabling HTTP requests/responses tracing")
-		cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
-			return logging.NewTransport("Kubernetes", rt)
-		}
+	if v, ok := data.GetOk("exec"); ok {
+		cfg.ExecProvider = expandExecConfig(v.([]interface{}))
 	}
-
-	k, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to configure: %s", err)
+	// All clients are built from cfg, so impersonation applies to every one of them
+	if v, ok := data.GetOk("impersonate"); ok {
+		cfg.Impersonate = expandImpersonationConfig(v.([]interface{}))
 	}
 
-	log.Println("[DEBUG] kudo provider configure:")
-	c := Config{}
-	c.KubernetesConfig = cfg
-	c.KubernetesClient = k
-	c.RawKudoClient, err = versioned.NewForConfig(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to obtain client to KUDO CRDs: %v", err)
+	if v, ok := data.GetOk("tls_server_name"); ok {
+		cfg.ServerName = v.(string)
 	}
-	c.KudoClient = kudo.NewClientFromK8s(c.RawKudoClient, c.KubernetesClient)
-
-	extClient, err := apiextensionsclient.NewForConfig(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("could not get Kubernetes client: %s", err)
-	}
-	dynamicClient, err := dynamic.NewForConfig(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("could not create Kubernetes dynamic client: %s", err)
+	if v, ok := data.GetOk("request_timeout"); ok {
+		timeout, err := time.ParseDuration(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse request_timeout: %s", err)
+		}
+		cfg.Timeout = timeout
+	}
+	// One token bucket for all clients, so client_qps is the limit for the whole provider
+	cfg.QPS = float32(data.Get("client_qps").(float64))
+	cfg.Burst = data.Get("client_burst").(int)
+	cfg.RateLimiter = limiter
+	if v, ok := data.GetOk("proxy_url"); ok {
+		proxyURL, err := url.Parse(v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse proxy_url: %s", err)
+		}
+		cfg.Wrap(proxyTransport(proxyURL))
 	}
 
-	c.KudoKubeClient = &kube.Client{
-		KubeClient:    c.KubernetesClient,
-		DynamicClient: dynamicClient,
-		ExtClient:     extClient,
+	if logging.IsDebugOrHigher() {
+		log.Printf("[DEBUG] Enabling HTTP requests/responses tracing")
+		cfg.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return logging.NewTransport("Kubernetes", rt)
+		})
 	}
 
-	//KUDO installation configurations
+	return cfg, nil
+}
 
-	if v, ok := data.GetOk("image"); ok {
-		c.KudoImage = v.(string)
+//proxyTransport sends requests through the given proxy instead of the one from the environment
+func proxyTransport(proxyURL *url.URL) transport.WrapperFunc {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		t, ok := rt.(*http.Transport)
+		if !ok {
+			log.Printf("[WARN] Unable to set proxy on transport %T", rt)
+			return rt
+		}
+		// the transport is cached by client-go, so don't modify it in place
+		t = t.Clone()
+		t.Proxy = http.ProxyURL(proxyURL)
+		return t
 	}
-	if v, ok := data.GetOk("kudo_version"); ok {
-		c.Version = v.(string)
-	} else {
-		c.Version = version.Get().String()
+}
+
+//expandExecConfig converts the exec block into a client-go exec credential plugin configuration
+func expandExecConfig(v []interface{}) *clientcmdapi.ExecConfig {
+	if len(v) == 0 || v[0] == nil {
+		return nil
 	}
-	if v, ok := data.GetOk("wait"); ok {
-		c.Wait = v.(bool)
+	exec := v[0].(map[string]interface{})
+
+	execConfig := &clientcmdapi.ExecConfig{
+		APIVersion: exec["api_version"].(string),
+		Command:    exec["command"].(string),
 	}
-	if v, ok := data.GetOk("wait_timeout"); ok {
-		c.WaitTimeout = v.(int)
+	for _, arg := range exec["args"].([]interface{}) {
+		execConfig.Args = append(execConfig.Args, arg.(string))
 	}
-	if v, ok := data.GetOk("service_account"); ok {
-		c.ServiceAccount = v.(string)
+	env := exec["env"].(map[string]interface{})
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
 	}
-	if v, ok := data.GetOk("namespace"); ok {
-		c.Namespace = v.(string)
+	sort.Strings(names)
+	for _, name := range names {
+		execConfig.Env = append(execConfig.Env, clientcmdapi.ExecEnvVar{
+			Name:  name,
+			Value: env[name].(string),
+		})
 	}
-	c.CRDsOnly = false
-
-	log.Printf("[DEBUG] Config %+v", c)
+	return execConfig
+}
 
-	//create KubeClient from kubeconfig
-	client := c.GetKudoKubernetesClient()
-	log.Printf("[DEBUG] Created the kube client from the config")
-	//install KUDO
-	log.Printf("[DEBUG] Running install")
-	opts := c.ToKUDOOpts()
-	log.Printf("[DEBUG] KUDO Opts: %+v", opts)
-
-	//try an install, but don't care if it succeeds or not so plans
-	// can be run without an active cluster
-	installer := setup.NewInstaller(opts, false)
-	if installer == nil {
-		log.Println("[ERROR] [KUDO] Error creating installer.")
-		return c, err
-	}
-	err = installer.Install(c.KudoKubeClient)
-	if err != nil {
-		log.Printf("[ERROR] [KUDO] Error installing KUDO: %+v", err)
-		return c, err
+//expandImpersonationConfig converts the impersonate block into the client-go impersonation settings
+func expandImpersonationConfig(v []interface{}) restclient.ImpersonationConfig {
+	impersonate := restclient.ImpersonationConfig{}
+	if len(v) == 0 || v[0] == nil {
+		return impersonate
 	}
+	in := v[0].(map[string]interface{})
 
-	err = setup.WatchKUDOUntilReady(c.KubernetesClient, opts, 600)
-
-	if err != nil {
-		log.Printf("[ERROR] [KUDO] Error installing KUDO: %+v", err)
-		return c, err
+	impersonate.UserName = in["user"].(string)
+	for _, group := range in["groups"].([]interface{}) {
+		impersonate.Groups = append(impersonate.Groups, group.(string))
 	}
-	//Wait for health of controller
-	return c, waitForControllerHealth(client, opts, time.Minute)
-}
-
-func waitForControllerHealth(client *kube.Client, opts kudoinit.Options, timeout time.Duration) error {
-
-	start := time.Now()
-	for {
-		time.Sleep(50 * time.Millisecond)
-		ss, err := client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get("kudo-controller-manager", metav1.GetOptions{})
-		if err != nil {
-			log.Printf("[DEBUG] Error getting kudo controller: %v\n", err)
-			continue
-		}
-		if ss.Status.ReadyReplicas == ss.Status.CurrentReplicas {
-			//Healthy!
-			return nil
+	for _, e := range in["extra"].([]interface{}) {
+		extra := e.(map[string]interface{})
+		if impersonate.Extra == nil {
+			impersonate.Extra = make(map[string][]string)
 		}
-		if time.Since(start) > timeout {
-			return fmt.Errorf("timed out waiting for healthy KUDO controller")
+		key := extra["key"].(string)
+		for _, value := range extra["values"].([]interface{}) {
+			impersonate.Extra[key] = append(impersonate.Extra[key], value.(string))
 		}
 	}
+	return impersonate
 }
 
 func tryLoadingConfigFile(d *schema.ResourceData) (*restclient.Config, error) {
-	path, err := homedir.Expand(d.Get("config_path").(string))
-	if err != nil {
-		return nil, err
-	}
-
-	loader := &clientcmd.ClientConfigLoadingRules{
-		ExplicitPath: path,
-	}
-
 	overrides := &clientcmd.ConfigOverrides{}
 	ctxSuffix := "; default context"
 
@@ -443,16 +690,77 @@ func tryLoadingConfigFile(d *schema.ResourceData) (*restclient.Config, error) {
 		log.Printf("[DEBUG] Using overidden context: %#v", overrides.Context)
 	}
 
-	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
+	var cc clientcmd.ClientConfig
+	var source string
+	if raw, ok := d.GetOk("config_raw"); ok {
+		source = "config_raw"
+		rawConfig, err := clientcmd.Load([]byte(raw.(string)))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse config (%s): %s", source, err)
+		}
+		cc = clientcmd.NewNonInteractiveClientConfig(*rawConfig, overrides.CurrentContext, overrides, nil)
+	} else {
+		paths, err := configPaths(d)
+		if err != nil {
+			return nil, err
+		}
+		loader := &clientcmd.ClientConfigLoadingRules{}
+		if len(paths) == 1 {
+			loader.ExplicitPath = paths[0]
+		} else {
+			// Same merging as a KUBECONFIG list: the first file to set a value wins
+			loader.Precedence = paths
+		}
+		source = strings.Join(paths, string(filepath.ListSeparator))
+		cc = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loader, overrides)
+	}
+
 	cfg, err := cc.ClientConfig()
 	if err != nil {
 		if pathErr, ok := err.(*os.PathError); ok && os.IsNotExist(pathErr.Err) {
-			log.Printf("[INFO] Unable to load config file as it doesn't exist at %q", path)
+			log.Printf("[INFO] Unable to load config file as it doesn't exist at %q", source)
+			return nil, nil
+		}
+		if clientcmd.IsEmptyConfig(err) && source != "config_raw" {
+			log.Printf("[INFO] Unable to load config as none of the files exist at %q", source)
 			return nil, nil
 		}
-		return nil, fmt.Errorf("Failed to load config (%s%s): %s", path, ctxSuffix, err)
+		return nil, fmt.Errorf("Failed to load config (%s%s): %s", source, ctxSuffix, err)
 	}
 
-	log.Printf("[INFO] Successfully loaded config file (%s%s)", path, ctxSuffix)
+	if !ctxOk && !authInfoOk && !clusterOk {
+		if rawConfig, err := cc.RawConfig(); err == nil {
+			ctxSuffix += fmt.Sprintf(": %s", rawConfig.CurrentContext)
+		}
+	}
+	log.Printf("[INFO] Successfully loaded config (%s%s)", source, ctxSuffix)
 	return cfg, nil
 }
+
+//configPaths returns the expanded kube config paths, in order of precedence. config_paths wins
+// over config_path, and the default path is only used when neither is set.
+func configPaths(d *schema.ResourceData) ([]string, error) {
+	var paths []string
+	if v, ok := d.GetOk("config_paths"); ok {
+		for _, p := range v.([]interface{}) {
+			paths = append(paths, p.(string))
+		}
+	} else if v, ok := d.GetOk("config_path"); ok {
+		paths = filepath.SplitList(v.(string))
+	} else {
+		paths = []string{defaultConfigPath}
+	}
+
+	expanded := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		path, err := homedir.Expand(p)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, path)
+	}
+	return expanded, nil
+}
@@ -1,11 +1,22 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+
+	restclient "k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	// "github.com/terraform-providers/terraform-provider-kubernetes/kubernetes"
 )
 
@@ -27,6 +38,39 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+//withDefaults fills in the defaults of the unset top-level attributes, like Terraform does before
+// it validates the provider configuration
+func withDefaults(t *testing.T, p *schema.Provider, raw map[string]interface{}) *terraform.ResourceConfig {
+	for k, s := range p.Schema {
+		if _, ok := raw[k]; ok {
+			continue
+		}
+		v, err := s.DefaultValue()
+		assert.NoError(t, err)
+		if v != nil {
+			raw[k] = v
+		}
+	}
+	return terraform.NewResourceConfigRaw(raw)
+}
+
+func TestProvider_validate(t *testing.T) {
+	p := Provider()
+	for _, raw := range []map[string]interface{}{
+		{},
+		{"config_path": "/etc/kube/config"},
+		{"config_paths": []interface{}{"/etc/kube/first", "/etc/kube/second"}},
+		{"config_raw": testKubeConfig("raw", "https://raw.example.com")},
+		{"config_path": "/etc/kube/config", "config_paths": []interface{}{"/etc/kube/first"}},
+	} {
+		warnings, errs := p.Validate(withDefaults(t, p, raw))
+		assert.Empty(t, errs, "%v", raw)
+		for _, w := range warnings {
+			assert.NotContains(t, w, "config_", "%v", raw)
+		}
+	}
+}
+
 func TestProvider_configure(t *testing.T) {
 	if os.Getenv("TF_ACC") != "" {
 		t.Skip("The environment variable TF_ACC is set, and this test prevents acceptance tests" +
@@ -44,3 +88,248 @@ func TestProvider_configure(t *testing.T) {
 	//Check to make sure the provider is healthy
 
 }
+
+func TestProvider_configureWithoutCluster(t *testing.T) {
+	rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"load_config_file": false,
+	})
+	p := Provider()
+	err := p.Configure(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing to connect to, so resources should plan as "to be created"
+	config := p.Meta().(Config)
+	_, err = config.GetKudoClient()
+	if !isClusterUnknown(err) {
+		t.Fatalf("expected the cluster to be unknown, got %v", err)
+	}
+
+	// but resources that are in the state aren't dropped from it
+	state := &terraform.InstanceState{
+		ID: id("kafka-1.3.1", "default"),
+		Attributes: map[string]string{
+			"operator_name":      "kafka",
+			"operator_namespace": "default",
+			"operator_version":   "1.3.1",
+			"object_name":        "kafka-1.3.1",
+		},
+	}
+	_, err = resourceOperator().RefreshWithoutUpgrade(state, config)
+	assert.Error(t, err)
+	assert.True(t, isClusterUnknown(err))
+	assert.Contains(t, err.Error(), "kafka-1.3.1_default is in the state")
+}
+
+func TestProvider_configureUnknownCluster(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testKubeConfig("local", "https://local.example.com")), 0600))
+
+	configure := func(raw map[string]interface{}) Config {
+		raw["config_path"] = path
+		p := Provider()
+		assert.NoError(t, p.Configure(terraform.NewResourceConfigRaw(raw)))
+		return p.Meta().(Config)
+	}
+
+	// the cluster is created in the same plan, so the local kube config isn't the one configured
+	for _, raw := range []map[string]interface{}{
+		{"host": unknownValue, "token": unknownValue},
+		{"cluster_ca_certificate": unknownValue},
+		{"config_paths": []interface{}{path, unknownValue}},
+		{"exec": []interface{}{map[string]interface{}{"api_version": "client.authentication.k8s.io/v1beta1", "command": unknownValue}}},
+	} {
+		_, err := configure(raw).GetKubernetesConfig()
+		assert.True(t, isClusterUnknown(err), "%v: %v", raw, err)
+	}
+
+	// once they are known, the kube config is loaded as before
+	cfg, err := configure(map[string]interface{}{"token": "secret"}).GetKubernetesConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://local.example.com", cfg.Host)
+	assert.Equal(t, "secret", cfg.BearerToken)
+}
+
+func TestExpandExecConfig(t *testing.T) {
+	assert.Nil(t, expandExecConfig([]interface{}{}))
+
+	exec := expandExecConfig([]interface{}{
+		map[string]interface{}{
+			"api_version": "client.authentication.k8s.io/v1alpha1",
+			"command":     "aws",
+			"args":        []interface{}{"eks", "get-token", "--cluster-name", "kudo"},
+			"env": map[string]interface{}{
+				"AWS_PROFILE": "kudo",
+				"AWS_REGION":  "us-west-2",
+			},
+		},
+	})
+	assert.Equal(t, &clientcmdapi.ExecConfig{
+		APIVersion: "client.authentication.k8s.io/v1alpha1",
+		Command:    "aws",
+		Args:       []string{"eks", "get-token", "--cluster-name", "kudo"},
+		Env: []clientcmdapi.ExecEnvVar{
+			{Name: "AWS_PROFILE", Value: "kudo"},
+			{Name: "AWS_REGION", Value: "us-west-2"},
+		},
+	}, exec)
+}
+
+func testKubeConfig(context, host string) string {
+	return fmt.Sprintf(`
+apiVersion: v1
+kind: Config
+current-context: %[1]s
+clusters:
+- name: %[1]s
+  cluster:
+    server: %[2]s
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[1]s
+users:
+- name: %[1]s
+  user:
+    token: secret
+`, context, host)
+}
+
+func TestTryLoadingConfigFile_raw(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config_raw": testKubeConfig("raw", "https://raw.example.com"),
+	})
+	cfg, err := tryLoadingConfigFile(d)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://raw.example.com", cfg.Host)
+
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config_raw":     testKubeConfig("raw", "https://raw.example.com"),
+		"config_context": "missing",
+	})
+	_, err = tryLoadingConfigFile(d)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "config_raw")
+	assert.Contains(t, err.Error(), "config ctx: missing")
+}
+
+func TestTryLoadingConfigFile_paths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kubeconfig")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	assert.NoError(t, ioutil.WriteFile(first, []byte(testKubeConfig("first", "https://first.example.com")), 0600))
+	assert.NoError(t, ioutil.WriteFile(second, []byte(testKubeConfig("second", "https://second.example.com")), 0600))
+
+	// The first file sets current-context, like KUBECONFIG=first:second
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config_paths": []interface{}{first, filepath.Join(dir, "missing"), second},
+	})
+	cfg, err := tryLoadingConfigFile(d)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://first.example.com", cfg.Host)
+
+	// Contexts from later files are still available
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config_path":    strings.Join([]string{first, second}, string(filepath.ListSeparator)),
+		"config_context": "second",
+	})
+	cfg, err = tryLoadingConfigFile(d)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://second.example.com", cfg.Host)
+
+	// config_raw takes precedence over the files, and config_paths over config_path
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config_raw":  testKubeConfig("raw", "https://raw.example.com"),
+		"config_path": first,
+	})
+	cfg, err = tryLoadingConfigFile(d)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://raw.example.com", cfg.Host)
+
+	d = schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{
+		"config_paths": []interface{}{second},
+		"config_path":  first,
+	})
+	cfg, err = tryLoadingConfigFile(d)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://second.example.com", cfg.Host)
+}
+
+func TestConfigPaths_default(t *testing.T) {
+	os.Unsetenv("KUBE_CONFIG")
+	os.Unsetenv("KUBECONFIG")
+	d := schema.TestResourceDataRaw(t, Provider().Schema, map[string]interface{}{})
+	paths, err := configPaths(d)
+	assert.NoError(t, err)
+	home, err := homedir.Expand(defaultConfigPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{home}, paths)
+}
+
+func TestImpersonation(t *testing.T) {
+	rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"load_config_file": false,
+		"host":             "https://kubernetes.example.com",
+		"impersonate": []interface{}{
+			map[string]interface{}{
+				"user":   "tenant-a",
+				"groups": []interface{}{"tenants", "developers"},
+				"extra": []interface{}{
+					map[string]interface{}{
+						"key":    "scopes",
+						"values": []interface{}{"view", "edit"},
+					},
+				},
+			},
+		},
+	})
+	p := Provider()
+	err := p.Configure(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := p.Meta().(Config)
+	cfg, err := config.GetKubernetesConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, restclient.ImpersonationConfig{
+		UserName: "tenant-a",
+		Groups:   []string{"tenants", "developers"},
+		Extra:    map[string][]string{"scopes": {"view", "edit"}},
+	}, cfg.Impersonate)
+}
+
+func TestConnectionSettings(t *testing.T) {
+	rc := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"load_config_file": false,
+		"host":             "https://10.0.0.1",
+		"proxy_url":        "http://proxy.example.com:3128",
+		"tls_server_name":  "kubernetes.example.com",
+		"request_timeout":  "45s",
+	})
+	p := Provider()
+	err := p.Configure(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := p.Meta().(Config)
+	cfg, err := config.GetKubernetesConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "kubernetes.example.com", cfg.ServerName)
+	assert.Equal(t, 45*time.Second, cfg.Timeout)
+
+	rt := cfg.WrapTransport(&http.Transport{})
+	req, _ := http.NewRequest("GET", cfg.Host, nil)
+	proxy, err := rt.(*http.Transport).Proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.example.com:3128", proxy.String())
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// how often the time spent waiting on the limiter is logged
+const rateLimiterSummaryInterval = 10 * time.Second
+
+// Ensure flowcontrol.RateLimiter is implemented
+var _ flowcontrol.RateLimiter = &measuredRateLimiter{}
+
+//measuredRateLimiter is a token bucket shared by all clients of the provider that
+// keeps track of how long requests had to wait for a token
+type measuredRateLimiter struct {
+	flowcontrol.RateLimiter
+	burst int
+
+	mu          sync.Mutex
+	requests    int
+	throttled   int
+	waited      time.Duration
+	lastSummary time.Time
+	// reported is the number of throttled requests in the last logged summary
+	reported int
+}
+
+func newMeasuredRateLimiter(qps float32, burst int) *measuredRateLimiter {
+	return &measuredRateLimiter{
+		RateLimiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+		burst:       burst,
+		lastSummary: time.Now(),
+	}
+}
+
+//Accept blocks until a token is available
+func (l *measuredRateLimiter) Accept() {
+	start := time.Now()
+	l.RateLimiter.Accept()
+	l.record(time.Since(start))
+}
+
+//Wait blocks until a token is available or the context is done
+func (l *measuredRateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.RateLimiter.Wait(ctx)
+	l.record(time.Since(start))
+	return err
+}
+
+func (l *measuredRateLimiter) record(waited time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.requests++
+	// anything quicker than this was served straight from the bucket
+	if waited > time.Millisecond {
+		l.throttled++
+		l.waited += waited
+	}
+	if l.throttled > 0 && time.Since(l.lastSummary) > rateLimiterSummaryInterval {
+		l.logSummary()
+	}
+}
+
+//LogSummary logs how long requests waited so far, unless nothing was throttled since the last summary.
+// Throttling in short runs would otherwise never show up.
+func (l *measuredRateLimiter) LogSummary() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.throttled > l.reported {
+		l.logSummary()
+	}
+}
+
+func (l *measuredRateLimiter) logSummary() {
+	l.lastSummary = time.Now()
+	l.reported = l.throttled
+	log.Printf("[DEBUG] %s", l.summary())
+}
+
+//withThrottlingSummary logs the throttling of the provider's clients after every operation of r
+func withThrottlingSummary(r *schema.Resource) *schema.Resource {
+	wrap := func(f func(*schema.ResourceData, interface{}) error) func(*schema.ResourceData, interface{}) error {
+		if f == nil {
+			return nil
+		}
+		return func(d *schema.ResourceData, m interface{}) error {
+			defer m.(Config).logThrottling()
+			return f(d, m)
+		}
+	}
+	r.Create = wrap(r.Create)
+	r.Read = wrap(r.Read)
+	r.Update = wrap(r.Update)
+	r.Delete = wrap(r.Delete)
+	return r
+}
+
+//logThrottling logs a summary of the rate limiter if requests were throttled
+func (c Config) logThrottling() {
+	if c.rateLimiter != nil {
+		c.rateLimiter.LogSummary()
+	}
+}
+
+//Summary describes how long requests spent waiting on the limiter so far
+func (l *measuredRateLimiter) Summary() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.summary()
+}
+
+func (l *measuredRateLimiter) summary() string {
+	return fmt.Sprintf("client rate limiter (qps %v, burst %v): %v of %v requests throttled, waited %v in total",
+		l.QPS(), l.burst, l.throttled, l.requests, l.waited)
+}
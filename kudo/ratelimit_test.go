@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasuredRateLimiter(t *testing.T) {
+	l := newMeasuredRateLimiter(50, 1)
+
+	// the first request is served from the bucket, the others have to wait
+	l.Accept()
+	l.Accept()
+	assert.NoError(t, l.Wait(context.Background()))
+
+	assert.Equal(t, 3, l.requests)
+	assert.Equal(t, 2, l.throttled)
+	assert.True(t, l.waited > 0)
+	assert.Contains(t, l.Summary(), "2 of 3 requests throttled")
+}
+
+func TestMeasuredRateLimiterLogSummary(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	l := newMeasuredRateLimiter(50, 1)
+	config := Config{rateLimiter: l}
+
+	// nothing throttled, nothing logged
+	l.Accept()
+	config.logThrottling()
+	assert.Empty(t, out.String())
+
+	// a short burst is logged once the operation is done, long before the periodic summary
+	l.Accept()
+	config.logThrottling()
+	assert.Contains(t, out.String(), "1 of 2 requests throttled")
+
+	// and only again after more throttling
+	out.Reset()
+	config.logThrottling()
+	assert.Empty(t, out.String())
+
+	// configurations without a provider don't log
+	Config{}.logThrottling()
+}
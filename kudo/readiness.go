@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/crd"
+)
+
+//readinessCheck is a single condition KUDO has to meet before it can be used
+type readinessCheck struct {
+	description string
+	lw          cache.ListerWatcher
+	objType     runtime.Object
+	ready       func(obj runtime.Object) bool
+}
+
+//kudoReadinessChecks returns the checks for a full KUDO installation, in the order they become true.
+// With several controller replicas, only the leader has to be ready.
+func kudoReadinessChecks(client *kube.Client, opts InstallOptions) []readinessCheck {
+	ssReady := statefulSetReady
+	if opts.highlyAvailable() {
+		ssReady = statefulSetAvailable
+	}
+	checks := crdReadinessChecks(client)
+	checks = append(checks,
+		readinessCheck{
+			description: fmt.Sprintf("KUDO controller StatefulSet %s/%s to have ready replicas", opts.Namespace, kudoinit.DefaultManagerName),
+			lw: namedListWatch(kudoinit.DefaultManagerName,
+				func(o metav1.ListOptions) (runtime.Object, error) {
+					return client.KubeClient.AppsV1().StatefulSets(opts.Namespace).List(o)
+				},
+				func(o metav1.ListOptions) (watch.Interface, error) {
+					return client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Watch(o)
+				}),
+			objType: &appsv1.StatefulSet{},
+			ready: func(obj runtime.Object) bool {
+				return ssReady(obj.(*appsv1.StatefulSet))
+			},
+		},
+	)
+	if opts.highlyAvailable() {
+		checks = append(checks, leaderReadinessCheck(client, opts.Namespace, opts.LeaderElectionID))
+	}
+	checks = append(checks,
+		readinessCheck{
+			description: fmt.Sprintf("webhook service %s/%s to have endpoints", opts.Namespace, kudoinit.DefaultServiceName),
+			lw: namedListWatch(kudoinit.DefaultServiceName,
+				func(o metav1.ListOptions) (runtime.Object, error) {
+					return client.KubeClient.CoreV1().Endpoints(opts.Namespace).List(o)
+				},
+				func(o metav1.ListOptions) (watch.Interface, error) {
+					return client.KubeClient.CoreV1().Endpoints(opts.Namespace).Watch(o)
+				}),
+			objType: &corev1.Endpoints{},
+			ready: func(obj runtime.Object) bool {
+				return endpointsReady(obj.(*corev1.Endpoints))
+			},
+		},
+	)
+	return checks
+}
+
+//crdReadinessChecks returns a check for every KUDO CRD to be Established
+func crdReadinessChecks(client *kube.Client) []readinessCheck {
+	checks := []readinessCheck{}
+	for _, obj := range crd.NewInitializer().Resources() {
+		name := obj.(*apiextv1beta1.CustomResourceDefinition).Name
+		checks = append(checks, readinessCheck{
+			description: fmt.Sprintf("CRD %s to be Established", name),
+			lw: namedListWatch(name,
+				func(o metav1.ListOptions) (runtime.Object, error) {
+					return client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().List(o)
+				},
+				func(o metav1.ListOptions) (watch.Interface, error) {
+					return client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Watch(o)
+				}),
+			objType: &apiextv1beta1.CustomResourceDefinition{},
+			ready: func(obj runtime.Object) bool {
+				return crdEstablished(obj.(*apiextv1beta1.CustomResourceDefinition))
+			},
+		})
+	}
+	return checks
+}
+
+//namedListWatch lists and watches a single object by name
+func namedListWatch(name string, listFunc cache.ListFunc, watchFunc cache.WatchFunc) cache.ListerWatcher {
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	return &cache.ListWatch{
+		ListFunc: func(o metav1.ListOptions) (runtime.Object, error) {
+			o.FieldSelector = selector
+			return listFunc(o)
+		},
+		WatchFunc: func(o metav1.ListOptions) (watch.Interface, error) {
+			o.FieldSelector = selector
+			return watchFunc(o)
+		},
+	}
+}
+
+//waitForReadiness watches until every check passes. All checks share the timeout,
+// and the error names the check that didn't pass in time.
+func waitForReadiness(checks []readinessCheck, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, check := range checks {
+		log.Printf("[DEBUG] Waiting for %s", check.description)
+		ready := check.ready
+		_, err := watchtools.UntilWithSync(ctx, check.lw, check.objType, nil, func(e watch.Event) (bool, error) {
+			if e.Type == watch.Deleted {
+				return false, nil
+			}
+			return ready(e.Object), nil
+		})
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %v waiting for %s", timeout, check.description)
+		}
+		if err != nil {
+			return fmt.Errorf("failed waiting for %s: %v", check.description, err)
+		}
+		log.Printf("[DEBUG] Done waiting for %s after %v", check.description, time.Since(start))
+	}
+	return nil
+}
+
+func crdEstablished(crd *apiextv1beta1.CustomResourceDefinition) bool {
+	for _, c := range crd.Status.Conditions {
+		if c.Type == apiextv1beta1.Established {
+			return c.Status == apiextv1beta1.ConditionTrue
+		}
+	}
+	return false
+}
+
+//statefulSetReady is true once the StatefulSet has rolled out and all of its replicas are ready
+func statefulSetReady(ss *appsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if ss.Spec.Replicas != nil {
+		replicas = *ss.Spec.Replicas
+	}
+	if replicas == 0 || ss.Status.ObservedGeneration < ss.Generation {
+		return false
+	}
+	if ss.Status.UpdateRevision != "" && ss.Status.CurrentRevision != ss.Status.UpdateRevision {
+		return false
+	}
+	return ss.Status.ReadyReplicas == replicas
+}
+
+func endpointsReady(ep *corev1.Endpoints) bool {
+	for _, subset := range ep.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+)
+
+func TestStatefulSetReady(t *testing.T) {
+	one := int32(1)
+	zero := int32(0)
+	tests := []struct {
+		name  string
+		ss    appsv1.StatefulSet
+		ready bool
+	}{
+		{"no replicas yet", appsv1.StatefulSet{}, false},
+		{"scaled to zero", appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: &zero}}, false},
+		{"ready", appsv1.StatefulSet{
+			Spec:   appsv1.StatefulSetSpec{Replicas: &one},
+			Status: appsv1.StatefulSetStatus{ReadyReplicas: 1, CurrentRevision: "a", UpdateRevision: "a"},
+		}, true},
+		{"rolling out", appsv1.StatefulSet{
+			Spec:   appsv1.StatefulSetSpec{Replicas: &one},
+			Status: appsv1.StatefulSetStatus{ReadyReplicas: 1, CurrentRevision: "a", UpdateRevision: "b"},
+		}, false},
+		{"not observed", appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 2},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &one},
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1, ObservedGeneration: 1},
+		}, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ready, statefulSetReady(&tt.ss), tt.name)
+	}
+}
+
+func testCRD(name string, established bool) *apiextv1beta1.CustomResourceDefinition {
+	status := apiextv1beta1.ConditionFalse
+	if established {
+		status = apiextv1beta1.ConditionTrue
+	}
+	return &apiextv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: apiextv1beta1.CustomResourceDefinitionStatus{
+			Conditions: []apiextv1beta1.CustomResourceDefinitionCondition{
+				{Type: apiextv1beta1.Established, Status: status},
+			},
+		},
+	}
+}
+
+func TestWaitForReadiness(t *testing.T) {
+	opts := InstallOptions{Namespace: kudoinit.DefaultNamespace}
+	one := int32(1)
+	ss := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: kudoinit.DefaultManagerName, Namespace: opts.Namespace},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &one},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+	}
+	ep := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: kudoinit.DefaultServiceName, Namespace: opts.Namespace},
+	}
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(ss, ep),
+		ExtClient: extfake.NewSimpleClientset(
+			testCRD("operators.kudo.dev", true),
+			testCRD("operatorversions.kudo.dev", true),
+			testCRD("instances.kudo.dev", true),
+		),
+	}
+
+	// the webhook service has no endpoints
+	err := waitForReadiness(kudoReadinessChecks(client, opts), time.Second)
+	assert.EqualError(t, err, "timed out after 1s waiting for webhook service kudo-system/kudo-controller-manager-service to have endpoints")
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ep.Subsets = []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}
+		_, _ = client.KubeClient.CoreV1().Endpoints(opts.Namespace).Update(ep)
+	}()
+	err = waitForReadiness(kudoReadinessChecks(client, opts), 5*time.Second)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/helper/validation"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/crd"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/verifier"
+)
+
+func resourceInstallation() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceInstallationCreate,
+		Read:          resourceInstallationRead,
+		Update:        resourceInstallationUpdate,
+		Delete:        resourceInstallationDelete,
+		CustomizeDiff: verifyHADiff,
+		Schema: map[string]*schema.Schema{
+			"kudo_version": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "0.14.0",
+				Description: "KUDO version to install. Changing it upgrades KUDO in place, waiting for the new controller up to wait_timeout.",
+			},
+			"allow_downgrade": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Allow changing kudo_version to a version older than the installed one",
+			},
+			"image": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "kudobuilder/controller",
+				Description: "Override KUDO controller base image",
+			},
+			"image_override": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Full controller image reference, including tag or digest. Replaces image, kudo_version and registry_mirror for the image.",
+			},
+			"registry_mirror": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Registry prefix that replaces the registry of the controller image, e.g. registry.example.com/dockerhub",
+			},
+			"image_pull_policy": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "Always",
+				ValidateFunc: validation.StringInSlice([]string{"Always", "IfNotPresent", "Never"}, false),
+				Description:  "Pull policy of the controller image",
+			},
+			"image_pull_secrets": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Names of secrets in the KUDO namespace used to pull the controller image",
+			},
+			"namespace": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     kudoinit.DefaultNamespace,
+				ForceNew:    true,
+				Description: "Namespace to install KUDO into",
+			},
+			"service_account": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "kudo-manager",
+				ForceNew:    true,
+				Description: "Override the default serviceAccount kudo-manager",
+			},
+			"crds_only": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "Only install the KUDO CRDs, without the controller. Always waits for the CRDs to be Established.",
+			},
+			"webhook_tls": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				ForceNew:    true,
+				Description: "How the serving certificate of the KUDO webhook is issued",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": &schema.Schema{
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      webhookTLSSelfSigned,
+							ValidateFunc: validation.StringInSlice([]string{webhookTLSSelfSigned, webhookTLSCertManager}, false),
+							Description:  "self_signed generates a CA in the provider, cert_manager requests the certificate from a cert-manager issuer",
+						},
+						"issuer_name": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Name of the cert-manager issuer. Required for cert_manager.",
+						},
+						"issuer_kind": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "Issuer",
+							Description: "Kind of the issuer, an Issuer in the KUDO namespace or a ClusterIssuer",
+						},
+						"issuer_group": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "API group of the issuer, for issuers not provided by cert-manager itself",
+						},
+					},
+				},
+			},
+			"controller": &schema.Schema{
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "Scheduling and resources of the KUDO controller pod",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"requests": &schema.Schema{
+							Type:             schema.TypeMap,
+							Optional:         true,
+							Computed:         true,
+							Elem:             &schema.Schema{Type: schema.TypeString},
+							DiffSuppressFunc: suppressEquivalentQuantity,
+							Description:      "Resource requests of the manager container, defaults to cpu 100m and memory 50Mi",
+						},
+						"limits": &schema.Schema{
+							Type:             schema.TypeMap,
+							Optional:         true,
+							Elem:             &schema.Schema{Type: schema.TypeString},
+							DiffSuppressFunc: suppressEquivalentQuantity,
+							Description:      "Resource limits of the manager container",
+						},
+						"node_selector": &schema.Schema{
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Description: "Node labels the controller pod has to be scheduled on",
+						},
+						"toleration": &schema.Schema{
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "Taints the controller pod tolerates",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"key": &schema.Schema{
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"operator": &schema.Schema{
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "Equal",
+										ValidateFunc: validation.StringInSlice([]string{"Equal", "Exists"}, false),
+									},
+									"value": &schema.Schema{
+										Type:     schema.TypeString,
+										Optional: true,
+									},
+									"effect": &schema.Schema{
+										Type:         schema.TypeString,
+										Optional:     true,
+										ValidateFunc: validation.StringInSlice([]string{"", "NoSchedule", "PreferNoSchedule", "NoExecute"}, false),
+									},
+									"toleration_seconds": &schema.Schema{
+										Type:     schema.TypeInt,
+										Optional: true,
+									},
+								},
+							},
+						},
+						"affinity": &schema.Schema{
+							Type:             schema.TypeString,
+							Optional:         true,
+							ValidateFunc:     validation.StringIsJSON,
+							DiffSuppressFunc: suppressEquivalentAffinity,
+							Description:      "Affinity of the controller pod as JSON, e.g. from jsonencode()",
+						},
+						"priority_class_name": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "PriorityClass of the controller pod",
+						},
+						"termination_grace_period_seconds": &schema.Schema{
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      defaultTerminationGracePeriodSeconds,
+							ValidateFunc: validation.IntAtLeast(1),
+							Description:  "Termination grace period of the controller pod",
+						},
+						"leader_election_flag": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Argument the controller image turns on leader election with, e.g. --enable-leader-election. Added to the manager container with leader_election_id set.",
+						},
+						"leader_election_id_env": &schema.Schema{
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "Environment variable the controller image reads the leader_election_id from. Set on the manager container with leader_election_id set.",
+						},
+					},
+				},
+			},
+			"controller_replicas": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      1,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of KUDO controller replicas. More than one spreads them across zones and adds a PodDisruptionBudget. It requires a controller image that elects a leader, which the KUDO managers up to 0.14 don't, along with leader_election_id and controller.leader_election_flag or controller.leader_election_id_env to turn leader election on.",
+			},
+			"leader_election_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Name of the ConfigMap lock the controller elects its leader with, passed in the controller.leader_election_id_env variable. With controller_replicas > 1, wait only waits for the leader.",
+			},
+			"force": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Remove KUDO even though Instances or OperatorVersions still exist. Has to be applied before destroying.",
+			},
+			"delete_crds": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Delete the KUDO CRDs, and with them all Operators, OperatorVersions and Instances, when destroying. The controller is only removed once they are gone, waiting up to wait_timeout. Has to be applied before destroying.",
+			},
+			"wait": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Block until KUDO manager is running and ready to recieve requests",
+			},
+			"wait_timeout": &schema.Schema{
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     300,
+				Description: "Wait timeout to be used.",
+			},
+		},
+	}
+}
+
+//installOptionsFromResource builds the InstallOptions described by a kudo_installation resource
+func installOptionsFromResource(d *schema.ResourceData) (InstallOptions, error) {
+	o, err := expandInstallOptions(d)
+	if err != nil {
+		return o, err
+	}
+	o.Wait = d.Get("wait").(bool)
+	o.WaitTimeout = d.Get("wait_timeout").(int)
+	return o, nil
+}
+
+//expandInstallOptions builds the InstallOptions from the attributes kudo_installation shares with
+// kudo_installation_manifests
+func expandInstallOptions(d *schema.ResourceData) (InstallOptions, error) {
+	tls, err := expandWebhookTLS(d.Get("webhook_tls").([]interface{}))
+	if err != nil {
+		return InstallOptions{}, err
+	}
+	controller, err := expandControllerOptions(d.Get("controller").([]interface{}))
+	if err != nil {
+		return InstallOptions{}, err
+	}
+	o := InstallOptions{
+		KudoImage:      d.Get("image").(string),
+		Version:        d.Get("kudo_version").(string),
+		ServiceAccount: d.Get("service_account").(string),
+		CRDsOnly:       d.Get("crds_only").(bool),
+		Namespace:      d.Get("namespace").(string),
+		WebhookTLS:     tls,
+		Controller:     controller,
+
+		ImagePullPolicy:  d.Get("image_pull_policy").(string),
+		ImagePullSecrets: expandStringSlice(d.Get("image_pull_secrets").([]interface{})),
+		ImageOverride:    d.Get("image_override").(string),
+		RegistryMirror:   d.Get("registry_mirror").(string),
+
+		ControllerReplicas: int32(d.Get("controller_replicas").(int)),
+		LeaderElectionID:   d.Get("leader_election_id").(string),
+	}
+	return o, o.verifyHA()
+}
+
+func expandWebhookTLS(l []interface{}) (WebhookTLSOptions, error) {
+	if len(l) == 0 || l[0] == nil {
+		return WebhookTLSOptions{Mode: webhookTLSSelfSigned}, nil
+	}
+	in := l[0].(map[string]interface{})
+	tls := WebhookTLSOptions{
+		Mode:        in["mode"].(string),
+		IssuerName:  in["issuer_name"].(string),
+		IssuerKind:  in["issuer_kind"].(string),
+		IssuerGroup: in["issuer_group"].(string),
+	}
+	if tls.Mode == webhookTLSCertManager && tls.IssuerName == "" {
+		return tls, fmt.Errorf("webhook_tls: issuer_name is required when mode is %s", webhookTLSCertManager)
+	}
+	return tls, nil
+}
+
+func resourceInstallationCreate(d *schema.ResourceData, m interface{}) error {
+	opts, err := installOptionsFromResource(d)
+	if err != nil {
+		return err
+	}
+	log.Printf("[KUDO] Installing KUDO %v into %v", opts.Version, opts.Namespace)
+	config := m.(Config)
+	if err := config.checkManaged(); err != nil {
+		return err
+	}
+	client, err := config.GetKudoKubernetesClient()
+	if err != nil {
+		return err
+	}
+	if err := config.preflight(installationPermissions(opts, installedCertManagerGroup(client, certManagerGroups[0]))); err != nil {
+		return err
+	}
+
+	err = installKUDO(client, opts)
+	if err != nil {
+		return fmt.Errorf("error installing KUDO: %w", err)
+	}
+
+	d.SetId(opts.Namespace)
+	return resourceInstallationRead(d, m)
+}
+
+func resourceInstallationRead(d *schema.ResourceData, m interface{}) error {
+	namespace := d.Id()
+	config := m.(Config)
+	client, err := config.GetKudoKubernetesClient()
+	if err != nil {
+		return stateClientError(d, err)
+	}
+
+	if d.Get("crds_only").(bool) {
+		result := verifier.NewResult()
+		err = crd.NewInitializer().VerifyInstallation(client, &result)
+		if err != nil {
+			return fmt.Errorf("error getting KUDO CRDs: %w", err)
+		}
+		if !result.IsValid() {
+			log.Printf("[KUDO] KUDO CRDs not installed: %v", result.ErrorsAsString())
+			d.SetId("")
+		}
+		return nil
+	}
+
+	ss, err := client.KubeClient.AppsV1().StatefulSets(namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		log.Printf("[KUDO] KUDO controller not found in namespace %v", namespace)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting KUDO controller: %w", err)
+	}
+
+	d.Set("namespace", ss.Namespace)
+	d.Set("service_account", ss.Spec.Template.Spec.ServiceAccountName)
+	for _, c := range ss.Spec.Template.Spec.Containers {
+		if c.Name != "manager" {
+			continue
+		}
+		d.Set("image_pull_policy", string(c.ImagePullPolicy))
+		if d.Get("image_override").(string) != "" {
+			d.Set("image_override", c.Image)
+			continue
+		}
+		ref, ok := unmirrorImage(c.Image, d.Get("registry_mirror").(string))
+		if !ok {
+			// pulled from elsewhere, the image can't be attributed to any of the settings
+			log.Printf("[WARN] [KUDO] KUDO controller runs image %v, which is not pulled from the registry mirror", c.Image)
+			continue
+		}
+		if image, version, ok := splitControllerImage(ref); ok {
+			if d.Get("registry_mirror").(string) != "" {
+				// the mirror replaced the registry of the image, which can't be recovered
+				image = stripRegistry(image)
+				if stripRegistry(d.Get("image").(string)) == image {
+					image = d.Get("image").(string)
+				}
+			}
+			d.Set("image", image)
+			d.Set("kudo_version", version)
+		}
+	}
+	pullSecrets := []interface{}{}
+	for _, s := range ss.Spec.Template.Spec.ImagePullSecrets {
+		pullSecrets = append(pullSecrets, s.Name)
+	}
+	d.Set("image_pull_secrets", pullSecrets)
+	if ss.Spec.Replicas != nil {
+		d.Set("controller_replicas", int(*ss.Spec.Replicas))
+	}
+	if len(d.Get("controller").([]interface{})) > 0 {
+		d.Set("controller", flattenControllerOptions(ss, d.Get("leader_election_id").(string)))
+	}
+	return nil
+}
+
+//splitControllerImage splits a controller image of the form image:vVersion
+func splitControllerImage(ref string) (string, string, bool) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return "", "", false
+	}
+	tag := ref[i+1:]
+	if !strings.HasPrefix(tag, "v") {
+		return "", "", false
+	}
+	return ref[:i], strings.TrimPrefix(tag, "v"), true
+}
+
+func resourceInstallationUpdate(d *schema.ResourceData, m interface{}) error {
+	opts, err := installOptionsFromResource(d)
+	if err != nil {
+		return err
+	}
+	config := m.(Config)
+	if err := config.checkManaged(); err != nil {
+		return err
+	}
+	client, err := config.GetKudoKubernetesClient()
+	if err != nil {
+		return err
+	}
+	certManagerGroup := installedCertManagerGroup(client, certManagerGroups[0])
+	perms := installationPermissions(opts, certManagerGroup)
+	if d.HasChange("kudo_version") {
+		perms = installationUpgradePermissions(opts, certManagerGroup)
+	}
+	if err := config.preflight(perms); err != nil {
+		return err
+	}
+
+	if d.HasChange("kudo_version") {
+		// the version in state is only used if the running controller doesn't tell
+		old, _ := d.GetChange("kudo_version")
+		installed := old.(string)
+		if v, found, err := installedKUDOVersion(client, opts); err != nil {
+			return err
+		} else if found && v != "" {
+			installed = v
+		}
+		kudoClient, err := config.GetRawKudoClient()
+		if err != nil {
+			return err
+		}
+		err = upgradeKUDO(client, kudoClient, opts, installed, d.Get("allow_downgrade").(bool))
+		if err != nil {
+			return fmt.Errorf("error upgrading KUDO: %w", err)
+		}
+		return resourceInstallationRead(d, m)
+	}
+
+	//Install is idempotent: it recreates anything that went missing and updates
+	// the controller StatefulSet to the new image and controller settings
+	err = installKUDO(client, opts)
+	if err != nil {
+		return fmt.Errorf("error installing KUDO: %w", err)
+	}
+	return resourceInstallationRead(d, m)
+}
+
+func resourceInstallationDelete(d *schema.ResourceData, m interface{}) error {
+	opts, err := installOptionsFromResource(d)
+	if err != nil {
+		return err
+	}
+	opts.Namespace = d.Id()
+	config := m.(Config)
+	if err := config.checkManaged(); err != nil {
+		return err
+	}
+	client, err := config.GetKudoKubernetesClient()
+	if err != nil {
+		return err
+	}
+	kudoClient, err := config.GetRawKudoClient()
+	if err != nil {
+		return err
+	}
+
+	uninstall := UninstallOptions{
+		Force:      d.Get("force").(bool),
+		DeleteCRDs: d.Get("delete_crds").(bool),
+	}
+	if err := config.preflight(installationDeletePermissions(opts, uninstall, installedCertManagerGroup(client, ""))); err != nil {
+		return err
+	}
+
+	log.Printf("[KUDO] Removing KUDO from %v", opts.Namespace)
+	err = uninstallKUDO(client, kudoClient, opts, uninstall)
+	if err != nil {
+		return fmt.Errorf("error removing KUDO: %w", err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+func suppressEquivalentQuantity(k, old, new string, d *schema.ResourceData) bool {
+	o, err := resource.ParseQuantity(old)
+	if err != nil {
+		return false
+	}
+	n, err := resource.ParseQuantity(new)
+	if err != nil {
+		return false
+	}
+	return o.Cmp(n) == 0
+}
+
+func suppressEquivalentAffinity(k, old, new string, d *schema.ResourceData) bool {
+	var o, n corev1.Affinity
+	if json.Unmarshal([]byte(old), &o) != nil || json.Unmarshal([]byte(new), &n) != nil {
+		return false
+	}
+	return reflect.DeepEqual(o, n)
+}
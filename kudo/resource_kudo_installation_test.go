@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+)
+
+func TestSplitControllerImage(t *testing.T) {
+	tests := []struct {
+		ref     string
+		image   string
+		version string
+		ok      bool
+	}{
+		{"kudobuilder/controller:v0.14.0", "kudobuilder/controller", "0.14.0", true},
+		{"registry:5000/kudobuilder/controller:v0.13.0", "registry:5000/kudobuilder/controller", "0.13.0", true},
+		{"registry:5000/kudobuilder/controller", "", "", false},
+		{"kudobuilder/controller:latest", "", "", false},
+		{"kudobuilder/controller", "", "", false},
+	}
+	for _, tt := range tests {
+		image, version, ok := splitControllerImage(tt.ref)
+		assert.Equal(t, tt.ok, ok, tt.ref)
+		assert.Equal(t, tt.image, image, tt.ref)
+		assert.Equal(t, tt.version, version, tt.ref)
+	}
+}
+
+func testAccCheckInstallationExists(namespace string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		config := testAccProvider.Meta().(Config)
+		client, err := config.GetKubernetesClient()
+		if err != nil {
+			return err
+		}
+		_, err = client.AppsV1().StatefulSets(namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+		return err
+	}
+}
+
+func TestKudoInstallation_create(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		IDRefreshName: "kudo_installation.test",
+		Providers:     testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testInstallation_basic("0.14.0"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					testAccCheckInstallationExists("kudo-system"),
+					resource.TestCheckResourceAttr("kudo_installation.test", "id", "kudo-system"),
+					resource.TestCheckResourceAttr("kudo_installation.test", "kudo_version", "0.14.0"),
+					resource.TestCheckResourceAttr("kudo_installation.test", "image", "kudobuilder/controller"),
+				),
+			},
+		},
+	})
+}
+
+func testInstallation_basic(version string) string {
+	return fmt.Sprintf(`
+resource "kudo_installation" "test" {
+	kudo_version = "%s"
+	wait         = true
+}
+`, version)
+}
@@ -47,6 +47,8 @@ func resourceInstance() *schema.Resource {
 			customdiff.ComputedIf("output_parameters", func(d *schema.ResourceDiff, meta interface{}) bool {
 				return d.HasChange("parameters")
 			}),
+			defaultNamespace("namespace", "operator_version_namespace"),
+			mergeDefaultMetadata,
 		),
 
 		//customdiff.ComputedIf("version", func(d *schema.ResourceDiff, meta interface{}) bool {
@@ -66,22 +68,44 @@ func resourceInstance() *schema.Resource {
 				Required: true,
 			},
 			"namespace": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				Default:  "default",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Namespace of the Instance, defaults to the default_namespace of the provider",
 			},
 			"labels": &schema.Schema{
-				Type:     schema.TypeMap,
-				Optional: true,
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Labels of the Instance, merged with the default_labels of the provider",
+			},
+			"labels_all": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "All labels of the Instance, including the default_labels of the provider",
+			},
+			"annotations": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Annotations of the Instance, merged with the default_annotations of the provider",
+			},
+			"annotations_all": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "All annotations of the Instance, including the default_annotations of the provider",
 			},
 			"operator_version_name": &schema.Schema{
 				Type:     schema.TypeString,
 				Required: true,
 			},
 			"operator_version_namespace": &schema.Schema{
-				Type:     schema.TypeString,
-				Optional: true,
-				Default:  "default",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "Namespace of the OperatorVersion, defaults to the default_namespace of the provider",
 			},
 			"pods": &schema.Schema{
 				Type:     schema.TypeList,
@@ -144,9 +168,12 @@ func customizeInstanceDiff(diff *schema.ResourceDiff, m interface{}) error {
 func resourceInstanceExists(d *schema.ResourceData, m interface{}) (bool, error) {
 	config := m.(Config)
 
-	client := config.GetKudoClient()
+	client, err := config.GetKudoClient()
+	if err != nil {
+		return false, stateClientError(d, err)
+	}
 
-	_, err := client.GetInstance(d.Get("name").(string), d.Get("namespace").(string))
+	_, err = client.GetInstance(d.Get("name").(string), d.Get("namespace").(string))
 
 	return err == nil, err
 }
@@ -163,18 +190,6 @@ func resourceInstanceCreate(d *schema.ResourceData, m interface{}) error {
 	} else {
 		d.Set("operator_version_namespace", operatorVersionNamespace)
 	}
-	//
-	labels := make(map[string]string)
-	inLabels, ok := d.GetOk("labels")
-	if ok {
-		for k, v := range inLabels.(map[string]interface{}) {
-			s, o := v.(string)
-			if o {
-				labels[k] = s
-			}
-		}
-	}
-
 	// operatorVersionNamespace := d.Get("operator_version_namespace").(string)
 	parametersI := d.Get("parameters").(map[string]interface{})
 	parameters := make(map[string]string)
@@ -183,13 +198,24 @@ func resourceInstanceCreate(d *schema.ResourceData, m interface{}) error {
 	}
 
 	config := m.(Config)
-	kudoClient := config.GetKudoClient()
+	if err := config.ensureKUDO(); err != nil {
+		return err
+	}
+	if err := config.preflight(instanceCreatePermissions(namespace, operatorVersionNamespace)); err != nil {
+		return err
+	}
+	kudoClient, err := config.GetKudoClient()
+	if err != nil {
+		return err
+	}
+	labels, annotations := resourceMetadata(d, config)
 
 	instance := &v1beta1.Instance{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Labels:    labels,
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
 		},
 		Spec: v1beta1.InstanceSpec{
 			Parameters: parameters,
@@ -200,7 +226,7 @@ func resourceInstanceCreate(d *schema.ResourceData, m interface{}) error {
 		},
 	}
 
-	instance, err := kudoClient.InstallInstanceObjToCluster(instance, instance.Namespace)
+	instance, err = kudoClient.InstallInstanceObjToCluster(instance, instance.Namespace)
 	if err != nil {
 		return fmt.Errorf("Error installing instance: %v", err)
 	}
@@ -236,8 +262,10 @@ func resourceInstanceRead(d *schema.ResourceData, m interface{}) error {
 
 	config := m.(Config)
 
-	//if cluster is not present, mark as "need to install"
-	kudoClient := config.GetKudoClient()
+	kudoClient, err := config.GetKudoClient()
+	if err != nil {
+		return stateClientError(d, err)
+	}
 
 	instance, err := kudoClient.GetInstance(name, namespace)
 	if err != nil {
@@ -249,11 +277,7 @@ func resourceInstanceRead(d *schema.ResourceData, m interface{}) error {
 		d.SetId("")
 		return nil //not present
 	}
-	labels := make(map[string]string)
-	for k, v := range instance.Labels {
-		labels[k] = v
-	}
-	d.Set("labels", labels)
+	setMetadata(d, config, instance.Labels, instance.Annotations)
 
 	operatorVersionName = instance.Spec.OperatorVersion.Name
 	operatorVersionNamespace = instance.Spec.OperatorVersion.Namespace
@@ -295,7 +319,10 @@ func resourceInstanceRead(d *schema.ResourceData, m interface{}) error {
 
 	// Cluster Resource
 
-	kubeClient := config.GetKubernetesClient()
+	kubeClient, err := config.GetKubernetesClient()
+	if err != nil {
+		return err
+	}
 
 	// the two common ways objects seem to be labeled
 	labelSelector1 := fmt.Sprintf("instance=%s", name)
@@ -470,7 +497,16 @@ func resourceInstanceUpdate(d *schema.ResourceData, m interface{}) error {
 	}
 
 	config := m.(Config)
-	kudoClient := config.GetKudoClient()
+	if err := config.ensureKUDO(); err != nil {
+		return err
+	}
+	if err := config.preflight(instanceUpdatePermissions(namespace, operatorVersionNamespace)); err != nil {
+		return err
+	}
+	kudoClient, err := config.GetKudoClient()
+	if err != nil {
+		return err
+	}
 
 	old, err := kudoClient.GetInstance(name, namespace)
 	if err != nil {
@@ -485,33 +521,26 @@ func resourceInstanceUpdate(d *schema.ResourceData, m interface{}) error {
 		same = same && parameters[k] == old.Spec.Parameters[k]
 	}
 
-	//
-	labels := make(map[string]string)
-	inLabels, ok := d.GetOk("labels")
-	if ok {
-
-		for k, v := range inLabels.(map[string]interface{}) {
-			s, o := v.(string)
-			if o {
-				labels[k] = s
-			}
-		}
-	}
 	newPlan := !same
 
-	//check labels
-	for k, v := range old.Labels {
-		same = same && labels[k] == v
-	}
-	for k, v := range labels {
-		same = same && old.Labels[k] == v
-	}
+	//check labels and annotations, ignoring the ones KUDO set
+	labels, annotations := resourceMetadata(d, config)
+	same = same && equalStringMaps(managedMetadata(old.Labels, labels), labels)
+	same = same && equalStringMaps(managedMetadata(old.Annotations, annotations), annotations)
 
 	if same {
 		//everything was the same, so don't actually update
 		return resourceInstanceRead(d, m)
 	}
-	err = patchInstance(config.RawKudoClient, name, namespace, parameters, labels, operatorVersionName)
+	rawKudoClient, err := config.GetRawKudoClient()
+	if err != nil {
+		return err
+	}
+	oldLabels, _ := d.GetChange("labels_all")
+	oldAnnotations, _ := d.GetChange("annotations_all")
+	metadata := metadataPatch(labels, expandStringMap(oldLabels.(map[string]interface{})),
+		annotations, expandStringMap(oldAnnotations.(map[string]interface{})))
+	err = patchInstance(rawKudoClient, name, namespace, parameters, metadata, operatorVersionName)
 	// err = kudoClient.UpdateInstance(name, namespace, &operatorVersionName, parameters)
 
 	if err != nil {
@@ -524,22 +553,18 @@ func resourceInstanceUpdate(d *schema.ResourceData, m interface{}) error {
 
 }
 
-func patchInstance(c *versioned.Clientset, instanceName, namespace string, parameters, labels map[string]string, ovName string) error {
+func patchInstance(c *versioned.Clientset, instanceName, namespace string, parameters map[string]string, metadata map[string]interface{}, ovName string) error {
 	instanceSpec := v1beta1.InstanceSpec{}
-	metadata := metav1.ObjectMeta{}
 	if parameters != nil {
 		instanceSpec.Parameters = parameters
 	}
 	instanceSpec.OperatorVersion.Name = ovName
-	if labels != nil {
-		metadata.Labels = labels
-	}
 	serializedPatch, err := json.Marshal(struct {
-		Spec     *v1beta1.InstanceSpec `json:"spec"`
-		Metadata *metav1.ObjectMeta    `json:"metadata"`
+		Spec     *v1beta1.InstanceSpec  `json:"spec"`
+		Metadata map[string]interface{} `json:"metadata"`
 	}{
 		&instanceSpec,
-		&metadata,
+		metadata,
 	})
 	if err != nil {
 		return err
@@ -552,8 +577,11 @@ func patchInstance(c *versioned.Clientset, instanceName, namespace string, param
 func waitForInstance(d *schema.ResourceData, m interface{}, name, namespace string, oldInstance *v1beta1.Instance) error {
 	//Wait for status plan to be done
 	config := m.(Config)
-	kudoClient := config.GetKudoClient()
-	err := kudoClient.WaitForInstance(name, namespace, oldInstance, time.Second*300)
+	kudoClient, err := config.GetKudoClient()
+	if err != nil {
+		return err
+	}
+	err = kudoClient.WaitForInstance(name, namespace, oldInstance, time.Second*300)
 	if err != nil {
 		return err
 	}
@@ -566,15 +594,21 @@ func resourceInstanceDelete(d *schema.ResourceData, m interface{}) error {
 	name := d.Get("name").(string)
 	namespace := d.Get("namespace").(string)
 	config := m.(Config)
+	if err := config.preflight(instanceDeletePermissions(namespace, d.Get("cleanup_pvcs").(bool))); err != nil {
+		return err
+	}
 
-	kudoClientset := config.RawKudoClient
+	kudoClientset, err := config.GetRawKudoClient()
+	if err != nil {
+		return err
+	}
 
 	propagationPolicy := metav1.DeletePropagationForeground
 	options := &metav1.DeleteOptions{
 		PropagationPolicy: &propagationPolicy,
 	}
 
-	err := kudoClientset.KudoV1beta1().Instances(namespace).Delete(name, options)
+	err = kudoClientset.KudoV1beta1().Instances(namespace).Delete(name, options)
 	if err != nil {
 		return err
 	}
@@ -596,7 +630,10 @@ func resourceInstanceDelete(d *schema.ResourceData, m interface{}) error {
 	pvcs, ok := d.GetOk("pvcs")
 	if ok {
 		pvcList := pvcs.([]interface{})
-		kubeClient := config.GetKubernetesClient()
+		kubeClient, err := config.GetKubernetesClient()
+		if err != nil {
+			return err
+		}
 
 		propagationPolicy := metav1.DeletePropagationForeground
 		options := &metav1.DeleteOptions{
@@ -35,7 +35,10 @@ func testAccCheckInstancePods(id string, instance *v1beta1.Instance, count int)
 			return fmt.Errorf("Expected %v number of pods, saw %v in the state", count, podCount)
 		}
 		config := testAccProvider.Meta().(Config)
-		client := config.GetKubernetesClient()
+		client, err := config.GetKubernetesClient()
+		if err != nil {
+			return err
+		}
 		for index := 0; index < count; index++ {
 			//check pod
 			podName := i.Primary.Attributes[fmt.Sprintf("pods.%d", index)]
@@ -60,12 +63,14 @@ func testAccCheckInstanceExists(name, namespace string, i *v1beta1.Instance) res
 		// 	return fmt.Errorf("Not found: %s/%s", name, namespace)
 		// }
 		config := testAccProvider.Meta().(Config)
-		client := config.GetKudoClient()
+		client, err := config.GetKudoClient()
+		if err != nil {
+			return err
+		}
 		// name, namespace, err := idParts(rs.Primary.ID)
 		// if err != nil {
 		// 	return err
 		// }
-		var err error
 		i, err = client.GetInstance(name, namespace)
 		return err
 	}
@@ -171,7 +176,10 @@ func TestKudoInstance_label(t *testing.T) {
 func testAccCheckInstanceHasLabels(name, namespace string, labels map[string]string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
 		config := testAccProvider.Meta().(Config)
-		client := config.GetKudoClient()
+		client, err := config.GetKudoClient()
+		if err != nil {
+			return err
+		}
 		// name, namespace, err := idParts(rs.Primary.ID)
 		// if err != nil {
 		// 	return err
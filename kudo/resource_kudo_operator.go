@@ -4,17 +4,16 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/hashicorp/terraform-plugin-sdk/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
 	"github.com/spf13/afero"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
-	"github.com/kudobuilder/kudo/pkg/kudoctl/env"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/kudohome"
 	"github.com/kudobuilder/kudo/pkg/kudoctl/packages"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/packages/reader"
 	pkgresolver "github.com/kudobuilder/kudo/pkg/kudoctl/packages/resolver"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/util/kudo"
-	"github.com/kudobuilder/kudo/pkg/kudoctl/util/repo"
 )
 
 func resourceOperator() *schema.Resource {
@@ -24,6 +23,13 @@ func resourceOperator() *schema.Resource {
 		Update: resourceOperatorUpdate,
 		Delete: resourceOperatorDelete,
 		Exists: resourceOperatorExists,
+		CustomizeDiff: customdiff.All(
+			defaultNamespace("operator_namespace"),
+			mergeDefaultMetadata,
+			planRepositoryURL,
+			planPackageHash,
+			planPackage,
+		),
 		Schema: map[string]*schema.Schema{
 			"operator_name": &schema.Schema{
 				Type:     schema.TypeString,
@@ -37,19 +43,83 @@ func resourceOperator() *schema.Resource {
 			"operator_namespace": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
-				Default:     "default",
-				Description: "Namespace to install the Operator Version",
+				Computed:    true,
+				Description: "Namespace to install the Operator Version, defaults to the default_namespace of the provider",
 			},
 			"repo": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "Name of a kudo_repository, or of a repository in the KUDO repo config file. Without repo, the default kudo_repository is used.",
+				Computed:      true,
+				ConflictsWith: append([]string{"package_path"}, inlinePackageAttributes...),
+			},
+			"repo_url": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				ValidateFunc:  validateRepositoryURL,
+				Description:   "URL of the repository to resolve the operator from, instead of looking repo up",
+				ConflictsWith: append([]string{"package_path"}, inlinePackageAttributes...),
+			},
+			"package_path": &schema.Schema{
+				Type:          schema.TypeString,
+				Optional:      true,
+				Description:   "Operator package directory or .tgz to install instead of resolving the operator from a repository",
+				ConflictsWith: inlinePackageAttributes,
+			},
+			"operator_yaml": &schema.Schema{
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "operator.yaml of an inline operator package, instead of resolving the operator from a repository",
+			},
+			"params_yaml": &schema.Schema{
 				Type:        schema.TypeString,
 				Optional:    true,
-				Description: "Name of Repository in KUDO repo config file",
+				Description: "params.yaml of an inline operator package",
+			},
+			"templates": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Templates of an inline operator package, by file name",
+			},
+			"package_hash": &schema.Schema{
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "SHA-256 of the contents of package_path",
+			},
+			"resolved_repo_url": &schema.Schema{
+				Type:        schema.TypeString,
 				Computed:    true,
+				Description: "URL of the repository the operator was resolved from",
 			},
 			"object_name": &schema.Schema{
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			"labels": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Labels of the Operator and OperatorVersion, merged with the default_labels of the provider",
+			},
+			"labels_all": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "All labels of the OperatorVersion, including the default_labels of the provider",
+			},
+			"annotations": &schema.Schema{
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Annotations of the Operator and OperatorVersion, merged with the default_annotations of the provider",
+			},
+			"annotations_all": &schema.Schema{
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "All annotations of the OperatorVersion, including the default_annotations of the provider",
+			},
 		},
 	}
 }
@@ -59,20 +129,70 @@ func getOperatorVersionFromRepo(d *schema.ResourceData, m interface{}) (*package
 	repoName := d.Get("repo").(string)
 	opearatorVersion := d.Get("operator_version").(string)
 	name := d.Get("operator_name").(string)
-	// initialization of filesystem for all commands
-	fs := afero.NewOsFs()
 
-	repository, err := repo.ClientFromSettings(fs, kudohome.Home(env.DefaultKudoHome), repoName)
+	repository, err := m.(Config).operatorRepository(repoName, d.Get("repo_url").(string))
 	if err != nil {
 		return nil, fmt.Errorf("could not build operator repository: %w", err)
 	}
 	d.Set("repo", repository.Config.Name)
+	d.Set("resolved_repo_url", repository.Config.URL)
 
 	resolver := pkgresolver.New(repository)
 	//not sure if the versions are used here or not.
 	return resolver.Resolve(name, "", opearatorVersion)
 }
 
+// the kudo_operator attributes that define an inline operator package
+var inlinePackageAttributes = []string{"operator_yaml", "params_yaml", "templates"}
+
+//hasInlinePackage is true when the configuration defines an inline operator package
+func hasInlinePackage(get func(string) interface{}) bool {
+	return get("operator_yaml").(string) != "" || get("params_yaml").(string) != "" || len(get("templates").(map[string]interface{})) > 0
+}
+
+//resolveOperatorPackage assembles the inline package, reads the package at package_path, or resolves
+// the operator from its repository
+func resolveOperatorPackage(d *schema.ResourceData, m interface{}) (*packages.Package, error) {
+	if hasInlinePackage(d.Get) {
+		d.Set("package_hash", "")
+		d.Set("resolved_repo_url", "")
+		return readInlinePackage(d.Get, d.HasChange)
+	}
+	path := d.Get("package_path").(string)
+	if path == "" {
+		d.Set("package_hash", "")
+		return getOperatorVersionFromRepo(d, m)
+	}
+	fs := afero.NewOsFs()
+	pkg, err := readLocalPackage(fs, path, d.Get("operator_name").(string), packageVersion(d.Get, d.HasChange))
+	if err != nil {
+		return nil, err
+	}
+	hash, err := packageHash(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash operator package %s: %v", path, err)
+	}
+	d.Set("package_hash", hash)
+	d.Set("resolved_repo_url", "")
+	return pkg, nil
+}
+
+//readInlinePackage assembles the inline operator package of the resource
+func readInlinePackage(get func(string) interface{}, hasChange func(string) bool) (*packages.Package, error) {
+	return inlinePackage(get("operator_yaml").(string), get("params_yaml").(string),
+		expandStringMap(get("templates").(map[string]interface{})),
+		get("operator_name").(string), packageVersion(get, hasChange))
+}
+
+//packageVersion returns the operator_version a local or inline package has to have. A version that
+// didn't change may have been computed from an earlier package, so the package's own version wins.
+func packageVersion(get func(string) interface{}, hasChange func(string) bool) string {
+	if !hasChange("operator_version") {
+		return ""
+	}
+	return get("operator_version").(string)
+}
+
 func resourceOperatorCreate(d *schema.ResourceData, m interface{}) error {
 	log.Printf("resourceOperatorCreate: %v %v\n", d, m)
 	name := d.Get("operator_name").(string)
@@ -84,20 +204,31 @@ func resourceOperatorCreate(d *schema.ResourceData, m interface{}) error {
 	log.Printf("[%v] Repo: %v", name, repoName)
 	log.Printf("[%v] Operator Version: %v", name, version)
 	config := m.(Config)
-	kudoClient := config.GetKudoClient()
+	if err := config.ensureKUDO(); err != nil {
+		return err
+	}
+	if err := config.preflight(operatorPermissions(namespace)); err != nil {
+		return err
+	}
+	kudoClient, err := config.GetRawKudoClient()
+	if err != nil {
+		return err
+	}
 
-	pkg, err := getOperatorVersionFromRepo(d, m)
+	pkg, err := resolveOperatorPackage(d, m)
 
 	if err != nil {
 		return fmt.Errorf("failed to resolve operator package for: %s %w", name, err)
 	}
-	log.Printf("[KUDO] [%v] Version pulled from repo: %+v", name, pkg.Resources.OperatorVersion.Spec.Version)
+	log.Printf("[KUDO] [%v] Version resolved: %+v", name, pkg.Resources.OperatorVersion.Spec.Version)
 
 	d.Set("operator_version", pkg.Resources.OperatorVersion.Spec.Version)
 	d.SetId(id(pkg.Resources.OperatorVersion.ObjectMeta.Name, namespace))
 	log.Printf("[KUDO] [%v] id set okay!", d.Id())
 	d.Set("object_name", pkg.Resources.OperatorVersion.ObjectMeta.Name)
 
+	labels, annotations := resourceMetadata(d, config)
+	addPackageMetadata(pkg, labels, annotations)
 	err = applyPackage(kudoClient, pkg, namespace)
 
 	if err != nil {
@@ -120,9 +251,12 @@ func resourceOperatorExists(d *schema.ResourceData, m interface{}) (bool, error)
 		return false, nil
 	}
 
-	client := config.GetKudoClient()
+	client, err := config.GetKudoClient()
+	if err != nil {
+		return false, stateClientError(d, err)
+	}
 
-	_, err := client.GetOperatorVersion(obj.(string), d.Get("operator_namespace").(string))
+	_, err = client.GetOperatorVersion(obj.(string), d.Get("operator_namespace").(string))
 
 	return err == nil, err
 }
@@ -142,7 +276,10 @@ func resourceOperatorRead(d *schema.ResourceData, m interface{}) error {
 	}
 
 	config := m.(Config)
-	client := config.GetKudoClient()
+	client, err := config.GetKudoClient()
+	if err != nil {
+		return stateClientError(d, err)
+	}
 
 	ov, err := client.GetOperatorVersion(ovName, namespace)
 	if err != nil {
@@ -156,6 +293,7 @@ func resourceOperatorRead(d *schema.ResourceData, m interface{}) error {
 	d.Set("operator_version", ov.Spec.Version)
 	d.Set("operator_name", ov.Spec.Operator.Name)
 	d.Set("object_name", ov.Name)
+	setMetadata(d, config, ov.Labels, ov.Annotations)
 	return nil
 }
 
@@ -163,72 +301,173 @@ func resourceOperatorUpdate(d *schema.ResourceData, m interface{}) error {
 	// return resourceOperatorCreate(d, m)
 	name := d.Get("operator_name").(string)
 	namespace := d.Get("operator_namespace").(string)
-	repoName := d.Get("repo").(string)
-	version := d.Get("operator_version").(string)
 	// ovName := d.Get("operator_version_name").(string)
 
 	config := m.(Config)
+	if err := config.ensureKUDO(); err != nil {
+		return err
+	}
+	if err := config.preflight(operatorPermissions(namespace)); err != nil {
+		return err
+	}
 
-	// initialization of filesystem for all commands
-	fs := afero.NewOsFs()
-
-	repository, err := repo.ClientFromSettings(fs, kudohome.Home(env.DefaultKudoHome), repoName)
+	kudoClient, err := config.GetRawKudoClient()
 	if err != nil {
-		return fmt.Errorf("could not build operator repository: %w", err)
+		return err
 	}
-	log.Printf("[KUDO] setting repo name to %v", repository.Config.Name)
-	d.Partial(true)
-	d.Set("repo", repository.Config.Name)
-	d.Partial(false)
-	kudoClient := config.GetKudoClient()
 
-	resolver := pkgresolver.New(repository)
-	//not sure if the versions are used here or not.
-	pkg, err := resolver.Resolve(name, "", version)
+	// nothing is saved unless the package applies, so that a failed update is planned again
+	d.Partial(true)
+	pkg, err := resolveOperatorPackage(d, m)
 	if err != nil {
 		return fmt.Errorf("failed to resolve operator package for: %s %w", name, err)
 	}
+	d.Set("operator_version", pkg.Resources.OperatorVersion.Spec.Version)
+	d.SetId(id(pkg.Resources.OperatorVersion.ObjectMeta.Name, namespace))
+	d.Set("object_name", pkg.Resources.OperatorVersion.ObjectMeta.Name)
 
+	labels, annotations := resourceMetadata(d, config)
+	addPackageMetadata(pkg, labels, annotations)
 	err = applyPackage(kudoClient, pkg, namespace)
 	if err != nil {
 		return err
 	}
+	d.Partial(false)
+	if d.HasChange("labels_all") || d.HasChange("annotations_all") {
+		oldLabels, _ := d.GetChange("labels_all")
+		oldAnnotations, _ := d.GetChange("annotations_all")
+		patch, err := marshalMetadataPatch(labels, expandStringMap(oldLabels.(map[string]interface{})),
+			annotations, expandStringMap(oldAnnotations.(map[string]interface{})))
+		if err != nil {
+			return err
+		}
+		_, err = kudoClient.KudoV1beta1().Operators(namespace).Patch(pkg.Resources.Operator.Name, types.MergePatchType, patch)
+		if err != nil {
+			return fmt.Errorf("error updating the metadata of Operator %s: %v", pkg.Resources.Operator.Name, err)
+		}
+		_, err = kudoClient.KudoV1beta1().OperatorVersions(namespace).Patch(pkg.Resources.OperatorVersion.Name, types.MergePatchType, patch)
+		if err != nil {
+			return fmt.Errorf("error updating the metadata of OperatorVersion %s: %v", pkg.Resources.OperatorVersion.Name, err)
+		}
+	}
 
 	log.Println("OperatorUpdate: ")
 	printOperatorConfig(d)
 	return resourceOperatorRead(d, m)
 }
 
-func applyPackage(kudoClient *kudo.Client, pkg *packages.Package, namespace string) error {
-	if kudoClient.OperatorExistsInCluster(pkg.Resources.Operator.Name, namespace) {
-		log.Printf("[KUDO] Operator %v already exists in the cluster.  Updates not supported", pkg.Resources.Operator.Name)
-	} else {
-		operator, err := kudoClient.InstallOperatorObjToCluster(pkg.Resources.Operator, namespace)
+//planRepositoryURL shows a change of the repository the operator resolves from as a diff, e.g.
+// when the url of its kudo_repository changed. The repository is looked up like during apply.
+func planRepositoryURL(d *schema.ResourceDiff, m interface{}) error {
+	if d.Get("package_path").(string) != "" || hasInlinePackage(d.Get) {
+		if d.Get("resolved_repo_url").(string) == "" {
+			return nil
+		}
+		return d.SetNew("resolved_repo_url", "")
+	}
+	if !d.NewValueKnown("repo") || !d.NewValueKnown("repo_url") {
+		return d.SetNewComputed("resolved_repo_url")
+	}
+	repository, err := m.(Config).operatorRepository(d.Get("repo").(string), d.Get("repo_url").(string))
+	if err != nil {
+		// e.g. a kudo_repository that is created in the same apply
+		log.Printf("[DEBUG] Can't resolve the repository of %v yet: %v", d.Get("operator_name"), err)
+		return d.SetNewComputed("resolved_repo_url")
+	}
+	if repository.Config.URL == d.Get("resolved_repo_url").(string) {
+		return nil
+	}
+	return d.SetNew("resolved_repo_url", repository.Config.URL)
+}
+
+//planPackageHash shows edits to the files of package_path as a diff
+func planPackageHash(d *schema.ResourceDiff, m interface{}) error {
+	if !d.NewValueKnown("package_path") {
+		return d.SetNewComputed("package_hash")
+	}
+	path := d.Get("package_path").(string)
+	hash := ""
+	if path != "" {
+		var err error
+		hash, err = packageHash(afero.NewOsFs(), path)
 		if err != nil {
-			log.Printf("[KUDO] [%v] Error installing Operator: %v", operator.Name, err)
-			return err
+			// e.g. a package written by another resource in the same apply
+			log.Printf("[DEBUG] Can't hash operator package %s yet: %v", path, err)
+			return d.SetNewComputed("package_hash")
 		}
 	}
-	if ov, err := kudoClient.GetOperatorVersion(pkg.Resources.OperatorVersion.Name, namespace); err == nil && ov != nil {
-		log.Printf("[KUDO] OperatorVersion %v already exists in the cluster.  Updates not supported", ov.Name)
-	} else {
-		ov, err = kudoClient.InstallOperatorVersionObjToCluster(pkg.Resources.OperatorVersion, namespace)
+	if hash == d.Get("package_hash").(string) {
+		return nil
+	}
+	return d.SetNew("package_hash", hash)
+}
+
+//planPackage verifies a local or inline operator package, and shows the OperatorVersion it defines in the plan
+func planPackage(d *schema.ResourceDiff, m interface{}) error {
+	var pkg *packages.Package
+	switch {
+	case hasInlinePackage(d.Get):
+		for _, k := range append([]string{"operator_name"}, inlinePackageAttributes...) {
+			if !d.NewValueKnown(k) {
+				return nil
+			}
+		}
+		var err error
+		pkg, err = readInlinePackage(d.Get, d.HasChange)
+		if err != nil {
+			return err
+		}
+	case d.Get("package_path").(string) != "":
+		if !d.NewValueKnown("package_path") || !d.NewValueKnown("operator_name") {
+			return nil
+		}
+		path := d.Get("package_path").(string)
+		var err error
+		pkg, err = reader.Read(afero.NewOsFs(), path)
 		if err != nil {
-			log.Printf("[KUDO] [%v] Error installing OperatorVersion: %v", pkg.Resources.OperatorVersion.Name, err)
+			// e.g. a package written by another resource in the same apply
+			log.Printf("[DEBUG] Can't read operator package %s yet: %v", path, err)
+			return d.SetNewComputed("object_name")
+		}
+		if err := checkPackage(pkg, "operator package "+path, d.Get("operator_name").(string), packageVersion(d.Get, d.HasChange)); err != nil {
 			return err
 		}
+	default:
+		return nil
+	}
+	if v := pkg.Resources.OperatorVersion.Spec.Version; v != d.Get("operator_version").(string) {
+		if err := d.SetNew("operator_version", v); err != nil {
+			return err
+		}
+	}
+	if name := pkg.Resources.OperatorVersion.Name; name != d.Get("object_name").(string) {
+		return d.SetNew("object_name", name)
 	}
 	return nil
 }
 
+//addPackageMetadata adds labels and annotations to the Operator and OperatorVersion of pkg
+func addPackageMetadata(pkg *packages.Package, labels, annotations map[string]string) {
+	for _, obj := range []*metav1.ObjectMeta{&pkg.Resources.Operator.ObjectMeta, &pkg.Resources.OperatorVersion.ObjectMeta} {
+		obj.Labels = mergeMetadata(obj.Labels, labels)
+		obj.Annotations = mergeMetadata(obj.Annotations, annotations)
+	}
+}
+
 //TODO implement uninstall here
 func resourceOperatorDelete(d *schema.ResourceData, m interface{}) error {
 	log.Printf("resourceOperatorCreate: %v %v\n", d, m)
 	name := d.Get("object_name").(string)
 	namespace := d.Get("operator_namespace").(string)
 	config := m.(Config)
+	if err := config.preflight(operatorDeletePermissions(namespace)); err != nil {
+		return err
+	}
 
-	kudoClientset := config.RawKudoClient
+	kudoClientset, err := config.GetRawKudoClient()
+	if err != nil {
+		return err
+	}
 
 	propagationPolicy := metav1.DeletePropagationBackground
 	options := &metav1.DeleteOptions{
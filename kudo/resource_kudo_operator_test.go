@@ -16,12 +16,15 @@ func testAccCheckOperatorExists(name, namespace string) resource.TestCheckFunc {
 		// 	return fmt.Errorf("Not found: %s/%s", name, namespace)
 		// }
 		config := testAccProvider.Meta().(Config)
-		client := config.GetKudoClient()
+		client, err := config.GetKudoClient()
+		if err != nil {
+			return err
+		}
 		// name, namespace, err := idParts(rs.Primary.ID)
 		// if err != nil {
 		// 	return err
 		// }
-		_, err := client.GetOperatorVersion(name, namespace)
+		_, err = client.GetOperatorVersion(name, namespace)
 		return err
 	}
 }
@@ -1 +1,237 @@
 package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/spf13/afero"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/env"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudohome"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/util/repo"
+)
+
+func resourceRepository() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceRepositoryCreate,
+		Read:   resourceRepositoryRead,
+		Update: resourceRepositoryUpdate,
+		Delete: resourceRepositoryDelete,
+		Schema: map[string]*schema.Schema{
+			"name": &schema.Schema{
+				Type:        schema.TypeString,
+				Required:    true,
+				ForceNew:    true,
+				Description: "Name kudo_operator refers to the repository by in repo",
+			},
+			"url": &schema.Schema{
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepositoryURL,
+				Description:  "URL of the repository, the directory its index.yaml is in",
+			},
+			"default": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Use the repository for kudo_operator resources that don't set repo",
+			},
+		},
+	}
+}
+
+//defaultRepositoryFile returns where the kudo_repository resources are kept without a
+// repository_file, in the Terraform data directory of the working directory
+func defaultRepositoryFile() string {
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	return filepath.Join(dataDir, "kudo", "repositories.yaml")
+}
+
+//repositoryStore keeps the repositories of kudo_repository resources in a repositories.yaml that
+// belongs to the provider, in the format of the one in the KUDO home. The default repository is
+// its context.
+type repositoryStore struct {
+	lock sync.Mutex
+	fs   afero.Fs
+	path string
+}
+
+func newRepositoryStore(fs afero.Fs, path string) *repositoryStore {
+	return &repositoryStore{fs: fs, path: path}
+}
+
+//load returns the stored repositories, none before the first one is added
+func (s *repositoryStore) load() (*repo.Repositories, error) {
+	exists, err := afero.Exists(s.fs, s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repository file %s: %v", s.path, err)
+	}
+	if !exists {
+		return &repo.Repositories{RepoVersion: repo.Version, Repositories: repo.Configurations{}}, nil
+	}
+	r, err := repo.LoadRepositories(s.fs, s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading repository file %s: %v", s.path, err)
+	}
+	return r, nil
+}
+
+//get returns the repository called name, or the default one without a name, and whether it is the
+// default. The repository is nil when there is none.
+func (s *repositoryStore) get(name string) (*repo.Configuration, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r, err := s.load()
+	if err != nil {
+		return nil, false, err
+	}
+	if name == "" {
+		name = r.Context
+	}
+	if name == "" {
+		return nil, false, nil
+	}
+	return r.GetConfiguration(name), r.Context == name, nil
+}
+
+//put adds the repository called name, or replaces it
+func (s *repositoryStore) put(name, url string, isDefault bool) error {
+	return s.update(func(r *repo.Repositories) {
+		r.Remove(name)
+		r.Add(&repo.Configuration{Name: name, URL: url})
+		if isDefault {
+			if r.Context != "" && r.Context != name {
+				log.Printf("[WARN] [KUDO] Both kudo_repository %s and %s are the default, using %s", r.Context, name, name)
+			}
+			r.Context = name
+		} else if r.Context == name {
+			r.Context = ""
+		}
+	})
+}
+
+//remove deletes the repository called name
+func (s *repositoryStore) remove(name string) error {
+	return s.update(func(r *repo.Repositories) {
+		r.Remove(name)
+		if r.Context == name {
+			r.Context = ""
+		}
+	})
+}
+
+//update changes the stored repositories with f and writes them back
+func (s *repositoryStore) update(f func(r *repo.Repositories)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r, err := s.load()
+	if err != nil {
+		return err
+	}
+	f(r)
+	if err := s.fs.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("error writing repository file %s: %v", s.path, err)
+	}
+	if err := r.WriteFile(s.fs, s.path, 0644); err != nil {
+		return fmt.Errorf("error writing repository file %s: %v", s.path, err)
+	}
+	return nil
+}
+
+//operatorRepository returns the client for the repository of a kudo_operator: repoURL if it is set,
+// otherwise the kudo_repository called repoName, or the default one without a name. Other names are
+// looked up in the repo config file of the KUDO home, and fail when it doesn't have them.
+func (c Config) operatorRepository(repoName, repoURL string) (*repo.Client, error) {
+	if repoURL != "" {
+		return repo.NewClient(&repo.Configuration{Name: repoName, URL: repoURL})
+	}
+	if c.repositories != nil {
+		conf, _, err := c.repositories.get(repoName)
+		if err != nil {
+			return nil, err
+		}
+		if conf != nil {
+			log.Printf("[DEBUG] Using kudo_repository %v", conf)
+			return repo.NewClient(conf)
+		}
+	}
+	return kudoHomeRepository(afero.NewOsFs(), kudohome.Home(env.DefaultKudoHome), repoName)
+}
+
+//kudoHomeRepository returns the client for the repository called repoName in the repo config file
+// of the KUDO home. Without the file, only KUDO's own default repository is known.
+func kudoHomeRepository(fs afero.Fs, home kudohome.Home, repoName string) (*repo.Client, error) {
+	r, err := repo.LoadRepositories(fs, home.RepositoryFile())
+	if err != nil {
+		if repoName == "" || repoName == repo.Default.Name {
+			return repo.NewClient(repo.Default)
+		}
+		return nil, fmt.Errorf("repository %s is neither a kudo_repository nor in the KUDO repo config file: %v", repoName, err)
+	}
+	conf := r.CurrentConfiguration()
+	if repoName != "" {
+		conf = r.GetConfiguration(repoName)
+	}
+	if conf == nil {
+		return nil, fmt.Errorf("repository %s is neither a kudo_repository nor in the KUDO repo config file %s", repoName, home.RepositoryFile())
+	}
+	return repo.NewClient(conf)
+}
+
+func validateRepositoryURL(v interface{}, k string) ([]string, []error) {
+	u, err := url.Parse(v.(string))
+	if err != nil {
+		return nil, []error{fmt.Errorf("%s is not a valid URL: %v", k, err)}
+	}
+	switch u.Scheme {
+	case "http", "https", "file":
+		return nil, nil
+	}
+	return nil, []error{fmt.Errorf("%s has to be an http, https or file URL, got %q", k, v)}
+}
+
+func resourceRepositoryCreate(d *schema.ResourceData, m interface{}) error {
+	name := d.Get("name").(string)
+	if err := m.(Config).repositories.put(name, d.Get("url").(string), d.Get("default").(bool)); err != nil {
+		return err
+	}
+	d.SetId(name)
+	return resourceRepositoryRead(d, m)
+}
+
+//resourceRepositoryRead reads the repository from the repository file of the provider. A repository
+// that is missing, e.g. in a new working directory, is added again by the next apply.
+func resourceRepositoryRead(d *schema.ResourceData, m interface{}) error {
+	conf, isDefault, err := m.(Config).repositories.get(d.Id())
+	if err != nil {
+		return err
+	}
+	if conf == nil {
+		log.Printf("[WARN] [KUDO] kudo_repository %s is missing from the repository file, removing it from the state", d.Id())
+		d.SetId("")
+		return nil
+	}
+	d.Set("name", conf.Name)
+	d.Set("url", conf.URL)
+	d.Set("default", isDefault)
+	return nil
+}
+
+func resourceRepositoryUpdate(d *schema.ResourceData, m interface{}) error {
+	if err := m.(Config).repositories.put(d.Id(), d.Get("url").(string), d.Get("default").(bool)); err != nil {
+		return err
+	}
+	return resourceRepositoryRead(d, m)
+}
+
+func resourceRepositoryDelete(d *schema.ResourceData, m interface{}) error {
+	return m.(Config).repositories.remove(d.Id())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/terraform"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudohome"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/util/repo"
+)
+
+//testRepositoryConfig returns a Config that keeps kudo_repository resources in memory
+func testRepositoryConfig() Config {
+	config := NewConfig(nil)
+	config.repositories = newRepositoryStore(afero.NewMemMapFs(), "/data/kudo/repositories.yaml")
+	return config
+}
+
+func TestResourceRepository(t *testing.T) {
+	config := testRepositoryConfig()
+	internal := schema.TestResourceDataRaw(t, resourceRepository().Schema, map[string]interface{}{
+		"name":    "internal",
+		"url":     "https://operators.example.com/kudo",
+		"default": true,
+	})
+	assert.NoError(t, resourceRepositoryCreate(internal, config))
+	assert.Equal(t, "internal", internal.Id())
+	mirror := schema.TestResourceDataRaw(t, resourceRepository().Schema, map[string]interface{}{
+		"name": "mirror",
+		"url":  "file:///srv/kudo",
+	})
+	assert.NoError(t, resourceRepositoryCreate(mirror, config))
+
+	// a new provider process finds them in the repository file
+	config.repositories = newRepositoryStore(config.repositories.fs, config.repositories.path)
+	client, err := config.operatorRepository("mirror", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "file:///srv/kudo", client.Config.URL)
+
+	// the default repository is used without a name
+	client, err = config.operatorRepository("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "internal", client.Config.Name)
+	assert.Equal(t, "https://operators.example.com/kudo", client.Config.URL)
+
+	// changes to the file are read back
+	assert.NoError(t, config.repositories.put("mirror", "file:///mnt/kudo", true))
+	assert.NoError(t, resourceRepositoryRead(mirror, config))
+	assert.Equal(t, "file:///mnt/kudo", mirror.Get("url"))
+	assert.True(t, mirror.Get("default").(bool))
+	assert.NoError(t, resourceRepositoryRead(internal, config))
+	assert.False(t, internal.Get("default").(bool))
+
+	assert.NoError(t, resourceRepositoryDelete(mirror, config))
+	conf, _, err := config.repositories.get("")
+	assert.NoError(t, err)
+	assert.Nil(t, conf)
+	assert.NoError(t, resourceRepositoryRead(mirror, config))
+	assert.Equal(t, "", mirror.Id())
+
+	// names that are neither a kudo_repository nor in the KUDO home don't resolve
+	_, err = config.operatorRepository("mirror", "")
+	assert.Error(t, err)
+}
+
+func TestKUDOHomeRepository(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	home := kudohome.Home("/kudo")
+
+	// without a repo config file, only KUDO's default repository is known
+	client, err := kudoHomeRepository(fs, home, "")
+	assert.NoError(t, err)
+	assert.Equal(t, repo.Default.URL, client.Config.URL)
+	_, err = kudoHomeRepository(fs, home, "internal")
+	assert.Error(t, err)
+
+	r := repo.NewRepositories()
+	r.Add(&repo.Configuration{Name: "internal", URL: "https://operators.example.com/kudo"})
+	assert.NoError(t, fs.MkdirAll("/kudo/repository", 0755))
+	assert.NoError(t, r.WriteFile(fs, home.RepositoryFile(), 0644))
+	client, err = kudoHomeRepository(fs, home, "internal")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://operators.example.com/kudo", client.Config.URL)
+	_, err = kudoHomeRepository(fs, home, "missing")
+	assert.Error(t, err)
+}
+
+func TestValidateRepositoryURL(t *testing.T) {
+	for _, u := range []string{"https://kudo-repository.storage.googleapis.com/0.10.0", "http://localhost:8080", "file:///srv/kudo"} {
+		_, errs := validateRepositoryURL(u, "url")
+		assert.Empty(t, errs, u)
+	}
+	for _, u := range []string{"kudo-repository.storage.googleapis.com", "s3://bucket/kudo", "http://[::1"} {
+		_, errs := validateRepositoryURL(u, "url")
+		assert.NotEmpty(t, errs, u)
+	}
+}
+
+func TestOperatorRepository(t *testing.T) {
+	config := testRepositoryConfig()
+	client, err := config.operatorRepository("internal", "https://operators.example.com/kudo")
+	assert.NoError(t, err)
+	assert.Equal(t, "internal", client.Config.Name)
+	assert.Equal(t, "https://operators.example.com/kudo", client.Config.URL)
+
+	// a changed repository URL shows in the plan of the operators resolved from it
+	state := &terraform.InstanceState{
+		ID: id("kafka-1.3.1", "default"),
+		Attributes: map[string]string{
+			"operator_name":      "kafka",
+			"operator_namespace": "default",
+			"repo":               "internal",
+			"repo_url":           "https://old.example.com/kudo",
+			"resolved_repo_url":  "https://old.example.com/kudo",
+			"labels_all.%":       "0",
+			"annotations_all.%":  "0",
+		},
+	}
+	cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"operator_name": "kafka",
+		"repo":          "internal",
+		"repo_url":      "https://operators.example.com/kudo",
+	})
+	diff, err := resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://operators.example.com/kudo", diff.Attributes["resolved_repo_url"].New)
+
+	state.Attributes["repo_url"] = "https://operators.example.com/kudo"
+	state.Attributes["resolved_repo_url"] = "https://operators.example.com/kudo"
+	diff, err = resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Nil(t, diff)
+
+	// so does a changed url of the kudo_repository it references by name
+	delete(state.Attributes, "repo_url")
+	assert.NoError(t, config.repositories.put("internal", "https://new.example.com/kudo", false))
+	cfg = terraform.NewResourceConfigRaw(map[string]interface{}{
+		"operator_name": "kafka",
+		"repo":          "internal",
+	})
+	diff, err = resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://new.example.com/kudo", diff.Attributes["resolved_repo_url"].New)
+
+	state.Attributes["resolved_repo_url"] = "https://new.example.com/kudo"
+	diff, err = resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.Nil(t, diff)
+
+	// a kudo_repository that doesn't exist yet is resolved during apply
+	cfg = terraform.NewResourceConfigRaw(map[string]interface{}{
+		"operator_name": "kafka",
+		"repo":          "later",
+	})
+	diff, err = resourceOperator().Diff(state, cfg, config)
+	assert.NoError(t, err)
+	assert.True(t, diff.Attributes["resolved_repo_url"].NewComputed)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/client/clientset/versioned"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+)
+
+//checkManaged fails when the provider is not allowed to change the KUDO installation
+func (c Config) checkManaged() error {
+	if !c.ManageKUDO {
+		return fmt.Errorf("KUDO is not managed by this provider, kudo_installation can't be used with manage_kudo = false")
+	}
+	return nil
+}
+
+//verifyUsableKUDO checks that an existing KUDO can be used by a tenant that isn't allowed to
+// install it: the CRDs are served, a controller runs and Instances can be created in namespaces
+func verifyUsableKUDO(client *kube.Client, kudoClient versioned.Interface, k *installedKUDO, namespaces []string) error {
+	problems := []string{}
+	for _, u := range k.Unchecked {
+		log.Printf("[WARN] [KUDO] Not allowed to check the %s, assuming it is usable", u)
+	}
+
+	// the CRDs are cluster-scoped and usually can't be read by tenants, listing
+	// Instances tells whether they exist as well
+	for _, ns := range namespaces {
+		_, err := kudoClient.KudoV1beta1().Instances(ns).List(metav1.ListOptions{Limit: 1})
+		if errors.IsNotFound(err) {
+			problems = append(problems, fmt.Sprintf("the KUDO %s API is not served, the KUDO CRDs are not installed", kudoAPIVersion))
+			break
+		}
+		if err != nil && !errors.IsForbidden(err) {
+			return fmt.Errorf("error listing KUDO instances in %s: %v", ns, err)
+		}
+	}
+	if k.Namespace == "" && !k.controllerUnchecked() {
+		problems = append(problems, "no KUDO controller is running in the cluster")
+	}
+
+	perms := []permission{}
+	for _, ns := range namespaces {
+		perms = append(perms, permissions(ns, kudoGroup, []string{"instances"}, "create", "get")...)
+		perms = append(perms, permissions(ns, kudoGroup, []string{"operators", "operatorversions"}, "get")...)
+	}
+	if err := checkPermissions(client.KubeClient, perms); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("KUDO can't be used with manage_kudo = false:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+//controllerUnchecked reports whether the credentials weren't allowed to look for the controller
+func (k *installedKUDO) controllerUnchecked() bool {
+	for _, u := range k.Unchecked {
+		if strings.HasPrefix(u, "KUDO controller") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	kudofake "github.com/kudobuilder/kudo/pkg/client/clientset/versioned/fake"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+)
+
+func TestVerifyUsableKUDO(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		// the tenant can only create Instances in its own namespace
+		review.Status.Allowed = review.Spec.ResourceAttributes.Namespace == "tenant"
+		return true, review, nil
+	})
+	client := &kube.Client{KubeClient: kubeClient}
+	kudoClient := kudofake.NewSimpleClientset()
+
+	// the CRDs and controller are not visible to tenants
+	k := &installedKUDO{Unchecked: []string{"CRD instances.kudo.dev", "KUDO controller in kudo-system"}}
+	assert.NoError(t, verifyUsableKUDO(client, kudoClient, k, []string{"tenant"}))
+
+	err := verifyUsableKUDO(client, kudoClient, k, []string{"tenant", "other"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "create instances.kudo.dev in namespace other")
+	assert.NotContains(t, err.Error(), "namespace tenant")
+
+	// no controller and no CRDs
+	kudoClient.PrependReactor("list", "instances", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewNotFound(schema.GroupResource{Group: kudoGroup, Resource: "instances"}, "")
+	})
+	err = verifyUsableKUDO(client, kudoClient, &installedKUDO{}, []string{"tenant"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "the KUDO CRDs are not installed")
+	assert.Contains(t, err.Error(), "no KUDO controller is running")
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/client/clientset/versioned"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/crd"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+)
+
+// the ClusterRoleBinding KUDO creates for its default service account
+const managerRoleBindingName = "kudo-manager-rolebinding"
+
+//UninstallOptions controls what removing a KUDO installation deletes
+type UninstallOptions struct {
+	Force      bool
+	DeleteCRDs bool
+}
+
+// how often uninstallKUDO checks whether the deleted CRDs are gone
+const crdDeletionPollInterval = time.Second
+
+//uninstallKUDO removes what installKUDO created. Unless forced, it refuses to run while any
+// Instances or OperatorVersions exist, as they can't be managed without the controller. Deleted
+// CRDs have to be gone within the wait timeout, before the controller is removed.
+func uninstallKUDO(client *kube.Client, kudoClient versioned.Interface, o InstallOptions, u UninstallOptions) error {
+	if !u.Force {
+		if err := checkNoKUDOObjects(kudoClient); err != nil {
+			return err
+		}
+	}
+
+	propagationPolicy := metav1.DeletePropagationForeground
+	options := &metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	}
+
+	// the CRDs go first, so that the controller is still around to clean up
+	// after the Instances that are deleted with them
+	if u.DeleteCRDs {
+		for _, obj := range crd.NewInitializer().Resources() {
+			name := obj.(*apiextv1beta1.CustomResourceDefinition).Name
+			log.Printf("[DEBUG] Deleting CRD %s", name)
+			err := client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Delete(name, options)
+			if err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("error deleting CRD %s: %v", name, err)
+			}
+		}
+		if err := waitForCRDDeletion(client, kudoClient, time.Duration(o.WaitTimeout)*time.Second); err != nil {
+			return err
+		}
+	}
+	if o.CRDsOnly {
+		return nil
+	}
+
+	kubeClient := client.KubeClient
+	err := kubeClient.AppsV1().StatefulSets(o.Namespace).Delete(kudoinit.DefaultManagerName, options)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting KUDO controller: %v", err)
+	}
+	err = kubeClient.PolicyV1beta1().PodDisruptionBudgets(o.Namespace).Delete(kudoinit.DefaultManagerName, options)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting KUDO controller disruption budget: %v", err)
+	}
+	err = kubeClient.CoreV1().Services(o.Namespace).Delete(kudoinit.DefaultServiceName, options)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting KUDO controller service: %v", err)
+	}
+	webhook := prereq.InstanceAdmissionWebhook(o.Namespace)
+	err = kubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Delete(webhook.Name, options)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting KUDO webhook configuration: %v", err)
+	}
+	//cert-manager would recreate the secret as long as the Certificate exists
+	err = deleteWebhookCertificate(client, o.Namespace)
+	if err != nil {
+		return fmt.Errorf("error deleting KUDO webhook certificate: %v", err)
+	}
+	err = kubeClient.CoreV1().Secrets(o.Namespace).Delete(kudoinit.DefaultSecretName, options)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error deleting KUDO webhook secret: %v", err)
+	}
+
+	// KUDO only creates the service account and namespace when the defaults are used,
+	// custom ones belong to whoever created them
+	opts := o.ToKUDOOpts()
+	if opts.IsDefaultServiceAccount() {
+		err = kubeClient.RbacV1().ClusterRoleBindings().Delete(managerRoleBindingName, options)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("error deleting KUDO role binding: %v", err)
+		}
+		err = kubeClient.CoreV1().ServiceAccounts(o.Namespace).Delete(o.ServiceAccount, options)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("error deleting KUDO service account: %v", err)
+		}
+	}
+	if opts.IsDefaultNamespace() {
+		err = kubeClient.CoreV1().Namespaces().Delete(o.Namespace, options)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("error deleting KUDO namespace: %v", err)
+		}
+	}
+	return nil
+}
+
+//waitForCRDDeletion waits until the deleted CRDs are gone. They stay Terminating while Instances
+// carry KUDO's cleanup finalizer, which only the controller removes.
+func waitForCRDDeletion(client *kube.Client, kudoClient versioned.Interface, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	remaining := []string{}
+	for {
+		remaining = []string{}
+		for _, obj := range crd.NewInitializer().Resources() {
+			name := obj.(*apiextv1beta1.CustomResourceDefinition).Name
+			_, err := client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get(name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error getting CRD %s: %v", name, err)
+			}
+			remaining = append(remaining, "CRD "+name)
+		}
+		if len(remaining) == 0 {
+			return nil
+		}
+		if time.Now().Add(crdDeletionPollInterval).After(deadline) {
+			break
+		}
+		log.Printf("[DEBUG] Waiting for the deletion of %s", strings.Join(remaining, ", "))
+		time.Sleep(crdDeletionPollInterval)
+	}
+	instances, listErr := kudoClient.KudoV1beta1().Instances("").List(metav1.ListOptions{})
+	if listErr == nil {
+		for _, i := range instances.Items {
+			remaining = append(remaining, fmt.Sprintf("Instance %s/%s", i.Namespace, i.Name))
+		}
+	}
+	sort.Strings(remaining)
+	return fmt.Errorf("timed out after %v waiting for the KUDO CRDs to be deleted, the KUDO controller was kept to clean up after:\n%s",
+		timeout, strings.Join(remaining, "\n"))
+}
+
+//checkNoKUDOObjects fails if any Instances or OperatorVersions exist in the cluster
+func checkNoKUDOObjects(kudoClient versioned.Interface) error {
+	remaining := []string{}
+	instances, err := kudoClient.KudoV1beta1().Instances("").List(metav1.ListOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error listing KUDO instances: %v", err)
+	}
+	if err == nil {
+		for _, i := range instances.Items {
+			remaining = append(remaining, fmt.Sprintf("Instance %s/%s", i.Namespace, i.Name))
+		}
+	}
+	ovs, err := kudoClient.KudoV1beta1().OperatorVersions("").List(metav1.ListOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("error listing KUDO operator versions: %v", err)
+	}
+	if err == nil {
+		for _, ov := range ovs.Items {
+			remaining = append(remaining, fmt.Sprintf("OperatorVersion %s/%s", ov.Namespace, ov.Name))
+		}
+	}
+	if len(remaining) > 0 {
+		sort.Strings(remaining)
+		return fmt.Errorf("KUDO can't be removed while it still manages:\n%s\nDelete them first or set force = true", strings.Join(remaining, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	kudofake "github.com/kudobuilder/kudo/pkg/client/clientset/versioned/fake"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+)
+
+func TestUninstallKUDO(t *testing.T) {
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extfake.NewSimpleClientset(),
+	}
+	opts := InstallOptions{
+		KudoImage:      "kudobuilder/controller",
+		Version:        "0.14.0",
+		Namespace:      kudoinit.DefaultNamespace,
+		ServiceAccount: "kudo-manager",
+	}
+	assert.NoError(t, installKUDO(client, opts))
+
+	// Instances and OperatorVersions block the removal
+	kudoClient := kudofake.NewSimpleClientset(testOperatorInstance("0.14.0")...)
+	err := uninstallKUDO(client, kudoClient, opts, UninstallOptions{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Instance default/pipes")
+	assert.Contains(t, err.Error(), "OperatorVersion default/kafka-1.2.0")
+	_, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, uninstallKUDO(client, kudoClient, opts, UninstallOptions{Force: true}))
+	_, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(prereq.InstanceAdmissionWebhook(opts.Namespace).Name, metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.KubeClient.RbacV1().ClusterRoleBindings().Get(managerRoleBindingName, metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.KubeClient.CoreV1().ServiceAccounts(opts.Namespace).Get(opts.ServiceAccount, metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.KubeClient.CoreV1().Namespaces().Get(opts.Namespace, metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// the CRDs are kept unless asked otherwise
+	_, err = client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get("instances.kudo.dev", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, uninstallKUDO(client, kudofake.NewSimpleClientset(), opts, UninstallOptions{DeleteCRDs: true}))
+	_, err = client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get("instances.kudo.dev", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestUninstallKUDOWaitsForCRDs(t *testing.T) {
+	extClient := extfake.NewSimpleClientset()
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient:  extClient,
+	}
+	opts := InstallOptions{
+		KudoImage:      "kudobuilder/controller",
+		Version:        "0.14.0",
+		Namespace:      kudoinit.DefaultNamespace,
+		ServiceAccount: "kudo-manager",
+	}
+	assert.NoError(t, installKUDO(client, opts))
+
+	// the Instance keeps its CRD Terminating until the controller removed its finalizer
+	extClient.PrependReactor("delete", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return action.(k8stesting.DeleteAction).GetName() == "instances.kudo.dev", nil, nil
+	})
+	kudoClient := kudofake.NewSimpleClientset(testOperatorInstance("0.14.0")...)
+	err := uninstallKUDO(client, kudoClient, opts, UninstallOptions{Force: true, DeleteCRDs: true})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "waiting for the KUDO CRDs to be deleted, the KUDO controller was kept")
+	assert.Contains(t, err.Error(), "CRD instances.kudo.dev")
+	assert.Contains(t, err.Error(), "Instance default/pipes")
+
+	_, err = client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get("operators.kudo.dev", metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	_, err = client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(prereq.InstanceAdmissionWebhook(opts.Namespace).Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kudobuilder/kudo/pkg/client/clientset/versioned"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/crd"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+	"github.com/kudobuilder/kudo/pkg/version"
+)
+
+//installedKUDOVersion returns the version of the running KUDO controller. The bool is false
+// if there is no controller, the version is empty if it can't be told from the controller image.
+func installedKUDOVersion(client *kube.Client, o InstallOptions) (string, bool, error) {
+	ss, err := client.KubeClient.AppsV1().StatefulSets(o.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error getting KUDO controller: %v", err)
+	}
+	return controllerVersion(ss), true, nil
+}
+
+//controllerVersion returns the KUDO version from the tag of the controller image, or an empty string
+func controllerVersion(ss *appsv1.StatefulSet) string {
+	for _, c := range ss.Spec.Template.Spec.Containers {
+		if c.Name != "manager" {
+			continue
+		}
+		// a registry mirror only changes the start of the image, not its tag
+		if _, v, ok := splitControllerImage(c.Image); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+//installOrUpgradeKUDO upgrades a running KUDO controller to the version in the options, or installs KUDO
+// if there is none. Downgrades are refused.
+func installOrUpgradeKUDO(client *kube.Client, kudoClient versioned.Interface, o InstallOptions) error {
+	installed, found, err := installedKUDOVersion(client, o)
+	if err != nil {
+		return err
+	}
+	if !found || installed == "" {
+		return installKUDO(client, o)
+	}
+	return upgradeKUDO(client, kudoClient, o, installed, false)
+}
+
+//upgradeKUDO moves KUDO from the installed version to the version in the options. After checking that
+// all Instances work with the new version, the CRDs are updated first, then the controller and, once
+// the new controller is ready, the webhook.
+func upgradeKUDO(client *kube.Client, kudoClient versioned.Interface, o InstallOptions, installed string, allowDowngrade bool) error {
+	from, err := version.New(installed)
+	if err != nil {
+		return fmt.Errorf("error parsing installed KUDO version %q: %v", installed, err)
+	}
+	to, err := version.New(o.Version)
+	if err != nil {
+		return fmt.Errorf("error parsing KUDO version %q: %v", o.Version, err)
+	}
+	switch c := to.Compare(from.Version); {
+	case c == 0:
+		return installKUDO(client, o)
+	case c < 0 && !allowDowngrade:
+		return fmt.Errorf("KUDO %v is installed, downgrading to %v requires allow_downgrade to be set", from, to)
+	}
+
+	if err := checkInstanceCompatibility(kudoClient, to); err != nil {
+		return err
+	}
+	installer := newInstaller(o)
+	if err := installer.verify(client); err != nil {
+		return err
+	}
+
+	log.Printf("[KUDO] Upgrading KUDO from %v to %v", from, to)
+	timeout := time.Duration(o.WaitTimeout) * time.Second
+
+	// the CRDs go first, so that the new controller finds the schema it expects
+	if err := updateCRDs(client); err != nil {
+		return err
+	}
+	if err := waitForReadiness(crdReadinessChecks(client), timeout); err != nil {
+		return err
+	}
+	if o.CRDsOnly {
+		return nil
+	}
+
+	// then the controller. The old webhook configuration keeps working until it is replaced below.
+	var webhook kudoinit.Step
+	for _, step := range installer.steps {
+		switch step.(type) {
+		case crd.Initializer:
+			continue
+		case *prereq.KudoWebHook, *certManagerWebhook:
+			webhook = step
+			continue
+		}
+		log.Printf("[DEBUG] Upgrading %s", step)
+		if err := step.Install(client); err != nil {
+			return fmt.Errorf("%s: %v", step, err)
+		}
+	}
+	if err := waitForReadiness(kudoReadinessChecks(client, o), timeout); err != nil {
+		return err
+	}
+
+	// and the webhook last, as its configuration may use handlers only the new controller serves
+	log.Printf("[DEBUG] Upgrading webhook")
+	if _, ok := webhook.(*certManagerWebhook); ok {
+		// updates the Certificate and webhook configuration in place
+		return webhook.Install(client)
+	}
+	return updateSelfSignedWebhook(client, webhook, o.Namespace)
+}
+
+//checkInstanceCompatibility fails if any Instance belongs to an Operator that requires a newer KUDO
+func checkInstanceCompatibility(kudoClient versioned.Interface, to *version.Version) error {
+	instances, err := kudoClient.KudoV1beta1().Instances("").List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing KUDO instances: %v", err)
+	}
+	problems := []string{}
+	for _, i := range instances.Items {
+		ov, err := kudoClient.KudoV1beta1().OperatorVersions(i.Namespace).Get(i.Spec.OperatorVersion.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error getting OperatorVersion %s/%s: %v", i.Namespace, i.Spec.OperatorVersion.Name, err)
+		}
+		operator, err := kudoClient.KudoV1beta1().Operators(i.Namespace).Get(ov.Spec.Operator.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error getting Operator %s/%s: %v", i.Namespace, ov.Spec.Operator.Name, err)
+		}
+		if operator.Spec.KudoVersion == "" {
+			continue
+		}
+		required, err := version.New(operator.Spec.KudoVersion)
+		if err != nil {
+			log.Printf("[WARN] [KUDO] Operator %s/%s has an invalid kudoVersion %q", operator.Namespace, operator.Name, operator.Spec.KudoVersion)
+			continue
+		}
+		if required.Compare(to.Version) > 0 {
+			problems = append(problems, fmt.Sprintf("Instance %s/%s of Operator %s requires KUDO %v", i.Namespace, i.Name, operator.Name, required))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("existing Instances are not compatible with KUDO %v:\n%s", to, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+//updateCRDs replaces the spec of the installed KUDO CRDs with the ones of this KUDO version
+func updateCRDs(client *kube.Client) error {
+	crds := client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions()
+	for _, obj := range crd.NewInitializer().Resources() {
+		desired := obj.(*apiextv1beta1.CustomResourceDefinition)
+		existing, err := crds.Get(desired.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			_, err = crds.Create(desired)
+			if err != nil {
+				return fmt.Errorf("error creating CRD %s: %v", desired.Name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error getting CRD %s: %v", desired.Name, err)
+		}
+		log.Printf("[DEBUG] Updating CRD %s", desired.Name)
+		existing.Spec = desired.Spec
+		_, err = crds.Update(existing)
+		if err != nil {
+			return fmt.Errorf("error updating CRD %s: %v", desired.Name, err)
+		}
+	}
+	return nil
+}
+
+//updateSelfSignedWebhook replaces the webhook configuration, keeping the CA bundle that belongs
+// to the existing serving certificate. A missing configuration is installed by the webhook step.
+func updateSelfSignedWebhook(client *kube.Client, step kudoinit.Step, namespace string) error {
+	webhooks := client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+	desired := prereq.InstanceAdmissionWebhook(namespace)
+	existing, err := webhooks.Get(desired.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return step.Install(client)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting webhook configuration: %v", err)
+	}
+	if len(existing.Webhooks) > 0 {
+		for i := range desired.Webhooks {
+			desired.Webhooks[i].ClientConfig.CABundle = existing.Webhooks[0].ClientConfig.CABundle
+		}
+	}
+	desired.ResourceVersion = existing.ResourceVersion
+	_, err = webhooks.Update(&desired)
+	if err != nil {
+		return fmt.Errorf("error updating webhook configuration: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/apis/kudo/v1beta1"
+	kudofake "github.com/kudobuilder/kudo/pkg/client/clientset/versioned/fake"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+	"github.com/kudobuilder/kudo/pkg/version"
+)
+
+func testOperatorInstance(kudoVersion string) []runtime.Object {
+	return []runtime.Object{
+		&v1beta1.Operator{
+			ObjectMeta: metav1.ObjectMeta{Name: "kafka", Namespace: "default"},
+			Spec:       v1beta1.OperatorSpec{KudoVersion: kudoVersion},
+		},
+		&v1beta1.OperatorVersion{
+			ObjectMeta: metav1.ObjectMeta{Name: "kafka-1.2.0", Namespace: "default"},
+			Spec:       v1beta1.OperatorVersionSpec{Operator: corev1.ObjectReference{Name: "kafka"}},
+		},
+		&v1beta1.Instance{
+			ObjectMeta: metav1.ObjectMeta{Name: "pipes", Namespace: "default"},
+			Spec:       v1beta1.InstanceSpec{OperatorVersion: corev1.ObjectReference{Name: "kafka-1.2.0"}},
+		},
+	}
+}
+
+func TestCheckInstanceCompatibility(t *testing.T) {
+	kudoClient := kudofake.NewSimpleClientset(testOperatorInstance("0.14.0")...)
+	assert.NoError(t, checkInstanceCompatibility(kudoClient, version.MustParse("0.14.0")))
+	assert.NoError(t, checkInstanceCompatibility(kudoClient, version.MustParse("0.15.1")))
+
+	err := checkInstanceCompatibility(kudoClient, version.MustParse("0.13.0"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Instance default/pipes of Operator kafka requires KUDO 0.14.0")
+}
+
+func TestUpgradeKUDO(t *testing.T) {
+	client := &kube.Client{
+		KubeClient: fake.NewSimpleClientset(),
+		ExtClient: extfake.NewSimpleClientset(
+			testCRD("operators.kudo.dev", true),
+			testCRD("operatorversions.kudo.dev", true),
+			testCRD("instances.kudo.dev", true),
+		),
+	}
+	kudoClient := kudofake.NewSimpleClientset(testOperatorInstance("0.13.0")...)
+	opts := InstallOptions{
+		KudoImage:      "kudobuilder/controller",
+		Version:        "0.13.0",
+		Namespace:      kudoinit.DefaultNamespace,
+		ServiceAccount: "kudo-manager",
+		WaitTimeout:    5,
+	}
+	assert.NoError(t, installKUDO(client, opts))
+
+	// the fake clientset doesn't run the controller, so make it ready by hand
+	ss, err := client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	ss.Status.ReadyReplicas = 1
+	_, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Update(ss)
+	assert.NoError(t, err)
+	_, err = client.KubeClient.CoreV1().Endpoints(opts.Namespace).Create(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: kudoinit.DefaultServiceName, Namespace: opts.Namespace},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	})
+	assert.NoError(t, err)
+	webhookName := prereq.InstanceAdmissionWebhook(opts.Namespace).Name
+	webhook, err := client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(webhookName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	caBundle := webhook.Webhooks[0].ClientConfig.CABundle
+	assert.NotEmpty(t, caBundle)
+
+	installed, found, err := installedKUDOVersion(client, opts)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "0.13.0", installed)
+
+	opts.Version = "0.14.0"
+	assert.NoError(t, upgradeKUDO(client, kudoClient, opts, installed, false))
+
+	ss, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "kudobuilder/controller:v0.14.0", ss.Spec.Template.Spec.Containers[0].Image)
+	webhook, err = client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(webhookName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, caBundle, webhook.Webhooks[0].ClientConfig.CABundle)
+
+	// going back needs an explicit opt-in
+	opts.Version = "0.13.0"
+	err = upgradeKUDO(client, kudoClient, opts, "0.14.0", false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "allow_downgrade")
+
+	// and the Instances have to support the older version
+	kudoClient = kudofake.NewSimpleClientset(testOperatorInstance("0.14.0")...)
+	err = upgradeKUDO(client, kudoClient, opts, "0.14.0", true)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not compatible with KUDO 0.13.0")
+}
@@ -18,3 +18,11 @@ func idParts(id string) (string, string, error) {
 func id(name, namespace string) string {
 	return fmt.Sprintf("%v_%v", name, namespace)
 }
+
+func expandStringSlice(l []interface{}) []string {
+	out := []string{}
+	for _, v := range l {
+		out = append(out, v.(string))
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/verifier"
+)
+
+const (
+	webhookTLSSelfSigned  = "self_signed"
+	webhookTLSCertManager = "cert_manager"
+
+	webhookCertificateName = "kudo-webhook-server-certificate"
+)
+
+// cert-manager API groups that can be detected, newest first
+var certManagerGroups = []string{"cert-manager.io", "certmanager.k8s.io"}
+
+//WebhookTLSOptions configures how the serving certificate of the KUDO webhook is issued
+type WebhookTLSOptions struct {
+	Mode        string
+	IssuerName  string
+	IssuerKind  string
+	IssuerGroup string
+}
+
+// Ensure IF is implemented
+var _ kudoinit.Step = &certManagerWebhook{}
+
+//certManagerWebhook installs the instance admission webhook with a serving certificate issued
+// by an existing cert-manager issuer. cert-manager's CA injector fills in the CA bundle.
+type certManagerWebhook struct {
+	opts kudoinit.Options
+	tls  WebhookTLSOptions
+
+	// detected by PreInstallVerify
+	group   string
+	version string
+}
+
+func newCertManagerWebhook(opts kudoinit.Options, tls WebhookTLSOptions) *certManagerWebhook {
+	return &certManagerWebhook{
+		opts: opts,
+		tls:  tls,
+	}
+}
+
+func (w *certManagerWebhook) String() string {
+	return "webhook"
+}
+
+//PreInstallVerify detects the cert-manager API and checks that the issuer exists
+func (w *certManagerWebhook) PreInstallVerify(client *kube.Client, result *verifier.Result) error {
+	group, version, err := detectCertManager(client)
+	if err != nil {
+		return err
+	}
+	if group == "" {
+		result.AddErrors(fmt.Sprintf("webhook_tls mode %s requires cert-manager, but none of the CRDs %v are installed. Install cert-manager or use mode %s",
+			webhookTLSCertManager, certificateCRDNames(), webhookTLSSelfSigned))
+		return nil
+	}
+	log.Printf("[DEBUG] Detected cert-manager API %s/%s", group, version)
+	w.group = group
+	w.version = version
+
+	if w.issuerGroup() != group {
+		// issuers of external controllers can't be looked up without knowing their resource
+		return nil
+	}
+	issuers := w.issuerResource()
+	if w.tls.IssuerKind == "ClusterIssuer" {
+		_, err = client.DynamicClient.Resource(issuers).Get(w.tls.IssuerName, metav1.GetOptions{})
+	} else {
+		_, err = client.DynamicClient.Resource(issuers).Namespace(w.opts.Namespace).Get(w.tls.IssuerName, metav1.GetOptions{})
+	}
+	if errors.IsNotFound(err) {
+		result.AddErrors(fmt.Sprintf("cert-manager %s %s not found in namespace %s", w.tls.IssuerKind, w.tls.IssuerName, w.opts.Namespace))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting cert-manager %s %s: %v", w.tls.IssuerKind, w.tls.IssuerName, err)
+	}
+	return nil
+}
+
+//Install creates the Certificate and the webhook configuration, or updates them to match
+func (w *certManagerWebhook) Install(client *kube.Client) error {
+	if w.group == "" {
+		return fmt.Errorf("cert-manager API not detected, PreInstallVerify has to run before Install")
+	}
+	if err := applyCertificate(client.DynamicClient, w.certificate()); err != nil {
+		return err
+	}
+
+	webhook := w.admissionWebhook()
+	webhooks := client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+	_, err := webhooks.Create(&webhook)
+	if errors.IsAlreadyExists(err) {
+		existing, err := webhooks.Get(webhook.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		webhook.ResourceVersion = existing.ResourceVersion
+		_, err = webhooks.Update(&webhook)
+		return err
+	}
+	return err
+}
+
+//Resources returns the objects Install creates. The cert-manager API defaults to cert-manager.io/v1alpha2 if it wasn't detected.
+func (w *certManagerWebhook) Resources() []runtime.Object {
+	webhook := w.admissionWebhook()
+	return []runtime.Object{&webhook, w.certificate()}
+}
+
+func (w *certManagerWebhook) apiGroup() string {
+	if w.group == "" {
+		return certManagerGroups[0]
+	}
+	return w.group
+}
+
+func (w *certManagerWebhook) apiVersion() string {
+	if w.version == "" {
+		return "v1alpha2"
+	}
+	return w.version
+}
+
+func (w *certManagerWebhook) issuerGroup() string {
+	if w.tls.IssuerGroup == "" {
+		return w.apiGroup()
+	}
+	return w.tls.IssuerGroup
+}
+
+func (w *certManagerWebhook) issuerResource() schema.GroupVersionResource {
+	resource := "issuers"
+	if w.tls.IssuerKind == "ClusterIssuer" {
+		resource = "clusterissuers"
+	}
+	return schema.GroupVersionResource{Group: w.apiGroup(), Version: w.apiVersion(), Resource: resource}
+}
+
+func (w *certManagerWebhook) admissionWebhook() admissionv1beta1.MutatingWebhookConfiguration {
+	webhook := prereq.InstanceAdmissionWebhook(w.opts.Namespace)
+	webhook.Annotations[fmt.Sprintf("%s/inject-ca-from", w.apiGroup())] = fmt.Sprintf("%s/%s", w.opts.Namespace, webhookCertificateName)
+	return webhook
+}
+
+func (w *certManagerWebhook) certificate() *unstructured.Unstructured {
+	dnsName := fmt.Sprintf("%s.%s.svc", kudoinit.DefaultServiceName, w.opts.Namespace)
+	issuerRef := map[string]interface{}{
+		"name": w.tls.IssuerName,
+		"kind": w.tls.IssuerKind,
+	}
+	if w.tls.IssuerGroup != "" {
+		issuerRef["group"] = w.tls.IssuerGroup
+	}
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": fmt.Sprintf("%s/%s", w.apiGroup(), w.apiVersion()),
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"name":      webhookCertificateName,
+				"namespace": w.opts.Namespace,
+			},
+			"spec": map[string]interface{}{
+				"commonName": dnsName,
+				"dnsNames":   []interface{}{dnsName},
+				"issuerRef":  issuerRef,
+				"secretName": kudoinit.DefaultSecretName,
+			},
+		},
+	}
+}
+
+//applyCertificate creates the Certificate, or replaces the spec of an existing one
+func applyCertificate(client dynamic.Interface, cert *unstructured.Unstructured) error {
+	gvk := cert.GroupVersionKind()
+	certs := client.Resource(schema.GroupVersionResource{Group: gvk.Group, Version: gvk.Version, Resource: "certificates"}).Namespace(cert.GetNamespace())
+	_, err := certs.Create(cert, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		existing, err := certs.Get(cert.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting webhook certificate: %v", err)
+		}
+		existing.Object["spec"] = cert.Object["spec"]
+		_, err = certs.Update(existing, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error updating webhook certificate: %v", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error creating webhook certificate: %v", err)
+	}
+	return nil
+}
+
+//deleteWebhookCertificate removes the webhook Certificate if cert-manager is installed
+func deleteWebhookCertificate(client *kube.Client, namespace string) error {
+	group, version, err := detectCertManager(client)
+	if err != nil || group == "" {
+		return err
+	}
+	certs := client.DynamicClient.Resource(schema.GroupVersionResource{Group: group, Version: version, Resource: "certificates"})
+	err = certs.Namespace(namespace).Delete(webhookCertificateName, &metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+//installedCertManagerGroup returns the API group of the installed cert-manager, or fallback
+func installedCertManagerGroup(client *kube.Client, fallback string) string {
+	group, _, err := detectCertManager(client)
+	if err != nil {
+		log.Printf("[DEBUG] Can't detect cert-manager: %v", err)
+	}
+	if group == "" {
+		return fallback
+	}
+	return group
+}
+
+//detectCertManager returns the group and storage version of the installed cert-manager Certificate CRD,
+// or empty strings if cert-manager isn't installed
+func detectCertManager(client *kube.Client) (string, string, error) {
+	for _, name := range certificateCRDNames() {
+		crd, err := client.ExtClient.ApiextensionsV1beta1().CustomResourceDefinitions().Get(name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("error detecting cert-manager CRD %s: %v", name, err)
+		}
+		return crd.Spec.Group, storageVersion(crd), nil
+	}
+	return "", "", nil
+}
+
+func certificateCRDNames() []string {
+	names := []string{}
+	for _, group := range certManagerGroups {
+		names = append(names, "certificates."+group)
+	}
+	return names
+}
+
+func storageVersion(crd *apiextv1beta1.CustomResourceDefinition) string {
+	for _, v := range crd.Spec.Versions {
+		if v.Storage {
+			return v.Name
+		}
+	}
+	return crd.Spec.Version
+}
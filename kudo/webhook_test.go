@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	extfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kube"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit"
+	"github.com/kudobuilder/kudo/pkg/kudoctl/kudoinit/prereq"
+)
+
+func testCertManagerCRD() *apiextv1beta1.CustomResourceDefinition {
+	return &apiextv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "certificates.cert-manager.io"},
+		Spec: apiextv1beta1.CustomResourceDefinitionSpec{
+			Group: "cert-manager.io",
+			Versions: []apiextv1beta1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha2", Served: true, Storage: false},
+				{Name: "v1alpha3", Served: true, Storage: true},
+			},
+		},
+	}
+}
+
+func testIssuer(namespace, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1alpha3",
+			"kind":       "Issuer",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+		},
+	}
+}
+
+func TestInstallKUDO_certManager(t *testing.T) {
+	opts := InstallOptions{
+		KudoImage:      "kudobuilder/controller",
+		Version:        "0.14.0",
+		Namespace:      kudoinit.DefaultNamespace,
+		ServiceAccount: "kudo-manager",
+		WebhookTLS: WebhookTLSOptions{
+			Mode:       webhookTLSCertManager,
+			IssuerName: "corp-ca",
+			IssuerKind: "Issuer",
+		},
+	}
+
+	// without cert-manager the installation fails before anything is created
+	client := &kube.Client{
+		KubeClient:    fake.NewSimpleClientset(),
+		ExtClient:     extfake.NewSimpleClientset(),
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+	}
+	err := installKUDO(client, opts)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "certificates.cert-manager.io")
+	_, err = client.KubeClient.AppsV1().StatefulSets(opts.Namespace).Get(kudoinit.DefaultManagerName, metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// the issuer has to exist
+	client.ExtClient = extfake.NewSimpleClientset(testCertManagerCRD())
+	err = installKUDO(client, opts)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Issuer corp-ca not found")
+
+	client.DynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testIssuer(opts.Namespace, "corp-ca"))
+	err = installKUDO(client, opts)
+	assert.NoError(t, err)
+
+	certs := schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1alpha3", Resource: "certificates"}
+	cert, err := client.DynamicClient.Resource(certs).Namespace(opts.Namespace).Get(webhookCertificateName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	issuerName, _, _ := unstructured.NestedString(cert.Object, "spec", "issuerRef", "name")
+	assert.Equal(t, "corp-ca", issuerName)
+	secretName, _, _ := unstructured.NestedString(cert.Object, "spec", "secretName")
+	assert.Equal(t, kudoinit.DefaultSecretName, secretName)
+
+	webhook, err := client.KubeClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(prereq.InstanceAdmissionWebhook(opts.Namespace).Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "kudo-system/"+webhookCertificateName, webhook.Annotations["cert-manager.io/inject-ca-from"])
+	assert.Empty(t, webhook.Webhooks[0].ClientConfig.CABundle)
+
+	// no self-signed secret is generated
+	_, err = client.KubeClient.CoreV1().Secrets(opts.Namespace).Get(kudoinit.DefaultSecretName, metav1.GetOptions{})
+	assert.Error(t, err)
+
+	// installing again updates the certificate in place
+	opts.WebhookTLS.IssuerName = "other-ca"
+	opts.WebhookTLS.IssuerKind = "ClusterIssuer"
+	opts.WebhookTLS.IssuerGroup = "example.com"
+	err = installKUDO(client, opts)
+	assert.NoError(t, err)
+	cert, err = client.DynamicClient.Resource(certs).Namespace(opts.Namespace).Get(webhookCertificateName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	issuerName, _, _ = unstructured.NestedString(cert.Object, "spec", "issuerRef", "name")
+	assert.Equal(t, "other-ca", issuerName)
+
+	assert.NoError(t, deleteWebhookCertificate(client, opts.Namespace))
+	_, err = client.DynamicClient.Resource(certs).Namespace(opts.Namespace).Get(webhookCertificateName, metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestExpandWebhookTLS(t *testing.T) {
+	tls, err := expandWebhookTLS(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, webhookTLSSelfSigned, tls.Mode)
+
+	_, err = expandWebhookTLS([]interface{}{map[string]interface{}{
+		"mode":         webhookTLSCertManager,
+		"issuer_name":  "",
+		"issuer_kind":  "Issuer",
+		"issuer_group": "",
+	}})
+	assert.Error(t, err)
+}